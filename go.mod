@@ -7,6 +7,7 @@ require (
 	github.com/go-logr/logr v1.4.1
 	k8s.io/api v0.30.1
 	k8s.io/apimachinery v0.30.1
+	k8s.io/client-go v0.30.1
 	k8s.io/cloud-provider v0.30.1
 	k8s.io/component-base v0.30.1
 	k8s.io/klog v1.0.0
@@ -99,7 +100,6 @@ require (
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	k8s.io/apiserver v0.30.1 // indirect
-	k8s.io/client-go v0.30.1 // indirect
 	k8s.io/component-helpers v0.30.1 // indirect
 	k8s.io/controller-manager v0.30.1 // indirect
 	k8s.io/kms v0.30.1 // indirect
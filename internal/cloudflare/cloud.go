@@ -1,16 +1,29 @@
 package cloudflare
 
 import (
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/internal/config"
 	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/pkg/cloudflare"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 )
 
 const (
 	providerName = "cloudflare"
+
+	informerResyncPeriod = 5 * time.Minute
+
+	// defaultClusterID matches the default --cluster-name of the controller manager
+	defaultClusterID = "kubernetes"
 )
 
 // providerVersion is set by the build process using -ldflags -X.
@@ -19,6 +32,7 @@ var providerVersion = "vUnknown"
 type cloud struct {
 	cfg    config.CloudflareCCMConfiguration
 	Client *cloudflare.CloudflareAPI
+	lbOps  *LoadBalancerOps
 }
 
 func newCloud(_ io.Reader) (cloudprovider.Interface, error) {
@@ -37,6 +51,23 @@ func newCloud(_ io.Reader) (cloudprovider.Interface, error) {
 		return nil, err
 	}
 
+	// Validate the defaults up front as services fall back to them on every update
+	if _, err := parseNotificationEmail(cfg.LoadBalancer.PoolNotificationEmail); err != nil {
+		return nil, fmt.Errorf("invalid default pool notification email: %w", err)
+	}
+
+	if _, err := parseNotificationFilter(cfg.LoadBalancer.PoolNotificationFilter); err != nil {
+		return nil, fmt.Errorf("invalid default pool notification filter: %w", err)
+	}
+
+	if _, err := parseOriginAddressSources(cfg.LoadBalancer.OriginAddressSources); err != nil {
+		return nil, fmt.Errorf("invalid default origin address sources: %w", err)
+	}
+
+	if _, err := labels.Parse(cfg.LoadBalancer.OriginNodeSelector); err != nil {
+		return nil, fmt.Errorf("invalid default origin node selector: %w", err)
+	}
+
 	klog.Info("Cloudflare Client init")
 
 	err = cloudflareClient.ValidateAll()
@@ -53,10 +84,48 @@ func newCloud(_ io.Reader) (cloudprovider.Interface, error) {
 	return &cloud{
 		cfg:    cfg,
 		Client: cloudflareClient,
+		lbOps:  &LoadBalancerOps{defaults: cfg.LoadBalancer},
 	}, nil
 }
 
-func (c *cloud) Initialize(_ cloudprovider.ControllerClientBuilder, _ <-chan struct{}) {
+func (c *cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
+	client := clientBuilder.ClientOrDie("cloudflare-cloud-controller-manager")
+	informerFactory := informers.NewSharedInformerFactory(client, informerResyncPeriod)
+
+	endpointSliceInformer := informerFactory.Discovery().V1().EndpointSlices()
+	serviceInformer := informerFactory.Core().V1().Services()
+	nodeInformer := informerFactory.Core().V1().Nodes()
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+
+	c.lbOps.client = client
+	c.lbOps.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponent})
+	c.lbOps.endpointSliceLister = endpointSliceInformer.Lister()
+	c.lbOps.serviceLister = serviceInformer.Lister()
+	c.lbOps.nodeLister = nodeInformer.Lister()
+	c.lbOps.hasSynced = func() bool {
+		return endpointSliceInformer.Informer().HasSynced() && serviceInformer.Informer().HasSynced() && nodeInformer.Informer().HasSynced()
+	}
+
+	loadBalancers := newLoadbalancers(c.Client, c.lbOps)
+
+	endpointSliceController, err := newEndpointSliceController(loadBalancers, c.lbOps, informerFactory)
+	if err != nil {
+		klog.Fatalf("Failed to create endpoint slice controller: %v", err)
+	}
+
+	informerFactory.Start(stop)
+
+	go endpointSliceController.Run(stop)
+
+	// The garbage collector deletes resources of services it can't find, which is only safe when the cluster ID
+	// is unique. The cluster name passed by the controller manager defaults to kubernetes, so it isn't enough
+	if c.cfg.GarbageCollector.Interval > 0 && c.cfg.LoadBalancer.ClusterID == "" {
+		klog.Warning("Garbage collector disabled, it requires CLOUDFLARE_CLUSTER_ID to be set")
+	} else if c.cfg.GarbageCollector.Interval > 0 {
+		go newGarbageCollector(loadBalancers, c.lbOps, c.cfg.GarbageCollector).Run(stop)
+	}
 }
 
 func (c *cloud) Instances() (cloudprovider.Instances, bool) {
@@ -74,9 +143,7 @@ func (c *cloud) Zones() (cloudprovider.Zones, bool) {
 }
 
 func (c *cloud) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
-	lbOps := &LoadBalancerOps{}
-
-	return newLoadbalancers(c.Client, lbOps), true
+	return newLoadbalancers(c.Client, c.lbOps), true
 }
 
 func (c *cloud) Clusters() (cloudprovider.Clusters, bool) {
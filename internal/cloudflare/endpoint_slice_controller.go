@@ -0,0 +1,185 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// endpointSliceController updates the pools of load balancers with externalTrafficPolicy Local
+// whenever their endpoints move between nodes, as the service controller only reacts to node changes
+type endpointSliceController struct {
+	loadBalancers *loadBalancers
+	lbOps         *LoadBalancerOps
+	queue         workqueue.RateLimitingInterface
+}
+
+func newEndpointSliceController(loadBalancers *loadBalancers, lbOps *LoadBalancerOps, informerFactory informers.SharedInformerFactory) (*endpointSliceController, error) {
+	c := &endpointSliceController{
+		loadBalancers: loadBalancers,
+		lbOps:         lbOps,
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "cloudflare-endpoint-slices"),
+	}
+
+	_, err := informerFactory.Discovery().V1().EndpointSlices().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueue,
+		UpdateFunc: func(oldObj, obj interface{}) {
+			if !endpointSliceNodesChanged(oldObj, obj) {
+				return
+			}
+
+			c.enqueue(obj)
+		},
+		DeleteFunc: c.enqueue,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add endpoint slice event handler: %w", err)
+	}
+
+	return c, nil
+}
+
+// enqueue adds the service owning an endpoint slice to the queue
+func (c *endpointSliceController) enqueue(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	endpointSlice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return
+	}
+
+	serviceName, ok := endpointSlice.Labels[discoveryv1.LabelServiceName]
+	if !ok {
+		return
+	}
+
+	c.queue.Add(endpointSlice.Namespace + "/" + serviceName)
+}
+
+// endpointSliceNodesChanged reports whether an update of an endpoint slice changes the ready endpoints per node,
+// which is all the pools depend on. Resyncs and changes of e.g. endpoint addresses or ports are skipped
+func endpointSliceNodesChanged(oldObj, obj interface{}) bool {
+	oldEndpointSlice, ok := oldObj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return true
+	}
+
+	endpointSlice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return true
+	}
+
+	if oldEndpointSlice.ResourceVersion == endpointSlice.ResourceVersion {
+		return false
+	}
+
+	return !maps.Equal(readyEndpointsPerNode(oldEndpointSlice), readyEndpointsPerNode(endpointSlice))
+}
+
+// Run processes the queue until stop is closed
+func (c *endpointSliceController) Run(stop <-chan struct{}) {
+	defer c.queue.ShutDown()
+
+	if !cache.WaitForCacheSync(stop, c.lbOps.hasSynced) {
+		klog.Error("Failed to sync caches for the endpoint slice controller")
+		return
+	}
+
+	go wait.Until(c.worker, time.Second, stop)
+
+	<-stop
+}
+
+func (c *endpointSliceController) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *endpointSliceController) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	err := c.sync(context.Background(), key.(string))
+	if err != nil {
+		klog.Warning("Failed to update load balancer pools for service ", key, ": ", err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *endpointSliceController) sync(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	// Hold the lock while checking the service so a concurrent deletion can't slip in between
+	unlock := c.lbOps.lockService(namespace, name)
+	defer unlock()
+
+	service, err := c.lbOps.serviceLister.Services(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyLocal {
+		return nil
+	}
+
+	// The service controller deletes the load balancer of a deleted service, updating its pools would recreate them
+	if service.DeletionTimestamp != nil {
+		return nil
+	}
+
+	if _, err := GetLoadBalancerHostName(service); err != nil {
+		return nil
+	}
+
+	// Until the service controller passed its cluster name the ownership markers of the service are unknown,
+	// its initial sync of every service updates the pools anyway
+	if !c.lbOps.clusterIDKnown() {
+		return nil
+	}
+
+	// Creating the load balancer is up to the service controller, pools are only updated once it exists
+	_, exists, err := c.loadBalancers.GetLoadBalancer(ctx, "", service)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	nodes, err := c.lbOps.nodeLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	klog.Info("Endpoints of service ", key, " changed, updating load balancer pools")
+
+	return c.loadBalancers.updateLoadBalancer(ctx, service, nodes)
+}
@@ -0,0 +1,89 @@
+package cloudflare
+
+import (
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestEndpointSlice returns an endpoint slice with an endpoint per node name and the given ready condition
+func newTestEndpointSlice(resourceVersion string, ready bool, nodeNames ...string) *discoveryv1.EndpointSlice {
+	endpointSlice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "web-abcde",
+			ResourceVersion: resourceVersion,
+			Labels:          map[string]string{discoveryv1.LabelServiceName: "web"},
+		},
+	}
+
+	for _, nodeName := range nodeNames {
+		endpointSlice.Endpoints = append(endpointSlice.Endpoints, discoveryv1.Endpoint{
+			NodeName:   &nodeName,
+			Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+		})
+	}
+
+	return endpointSlice
+}
+
+func TestEndpointSliceNodesChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		oldObj interface{}
+		obj    interface{}
+		want   bool
+	}{
+		{
+			name:   "resync",
+			oldObj: newTestEndpointSlice("1", true, "node-a"),
+			obj:    newTestEndpointSlice("1", true, "node-a"),
+			want:   false,
+		},
+		{
+			name:   "same ready nodes",
+			oldObj: newTestEndpointSlice("1", true, "node-a", "node-b"),
+			obj:    newTestEndpointSlice("2", true, "node-a", "node-b"),
+			want:   false,
+		},
+		{
+			name:   "endpoint moved to another node",
+			oldObj: newTestEndpointSlice("1", true, "node-a"),
+			obj:    newTestEndpointSlice("2", true, "node-b"),
+			want:   true,
+		},
+		{
+			name:   "additional endpoint on a node changes its weight",
+			oldObj: newTestEndpointSlice("1", true, "node-a", "node-b"),
+			obj:    newTestEndpointSlice("2", true, "node-a", "node-a", "node-b"),
+			want:   true,
+		},
+		{
+			name:   "endpoints became unready",
+			oldObj: newTestEndpointSlice("1", true, "node-a"),
+			obj:    newTestEndpointSlice("2", false, "node-a"),
+			want:   true,
+		},
+		{
+			name:   "unready endpoints stay unready",
+			oldObj: newTestEndpointSlice("1", false, "node-a"),
+			obj:    newTestEndpointSlice("2", false, "node-b"),
+			want:   false,
+		},
+		{
+			name:   "unknown objects",
+			oldObj: "unknown",
+			obj:    newTestEndpointSlice("2", true, "node-a"),
+			want:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := endpointSliceNodesChanged(test.oldObj, test.obj); got != test.want {
+				t.Errorf("endpointSliceNodesChanged() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
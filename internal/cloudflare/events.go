@@ -0,0 +1,51 @@
+package cloudflare
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// eventComponent is the source of the events recorded on services
+	eventComponent = "cloudflare-cloud-controller-manager"
+
+	// eventReasonInvalidAnnotation is recorded for every annotation of a service that fails validation
+	eventReasonInvalidAnnotation = "InvalidAnnotation"
+
+	// eventReasonNoOriginNodes is recorded when the origin node selector of a service matches no nodes
+	eventReasonNoOriginNodes = "NoOriginNodes"
+
+	// eventReasonResourceNotOwned is recorded when a cloudflare resource named like one of the service belongs to someone else
+	eventReasonResourceNotOwned = "ResourceNotOwned"
+
+	// eventReasonHostnameProvisioned is recorded when a load balancer is created for a hostname of a service
+	eventReasonHostnameProvisioned = "HostnameProvisioned"
+
+	// eventReasonHostnameMigrating is recorded before the resources of a previous hostname of a service are deleted
+	eventReasonHostnameMigrating = "HostnameMigrating"
+
+	// eventReasonHostnameMigrated is recorded once the resources of a previous hostname of a service are deleted
+	eventReasonHostnameMigrated = "HostnameMigrated"
+
+	// eventReasonHostnameMigrationFailed is recorded when the resources of a previous hostname of a service couldn't be deleted
+	eventReasonHostnameMigrationFailed = "HostnameMigrationFailed"
+)
+
+// recordWarning records a warning event on the service. Events are dropped until the
+// cloud provider is initialized
+func (o *LoadBalancerOps) recordWarning(service *v1.Service, reason string, message string) {
+	if o.recorder == nil {
+		return
+	}
+
+	o.recorder.Event(service, v1.EventTypeWarning, reason, message)
+}
+
+// recordNormal records a normal event on the service. Events are dropped until the
+// cloud provider is initialized
+func (o *LoadBalancerOps) recordNormal(service *v1.Service, reason string, message string) {
+	if o.recorder == nil {
+		return
+	}
+
+	o.recorder.Event(service, v1.EventTypeNormal, reason, message)
+}
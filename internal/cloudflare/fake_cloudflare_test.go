@@ -0,0 +1,236 @@
+package cloudflare
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	cloudflareClient "github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/pkg/cloudflare"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+const (
+	fakeLoadBalancers = "load_balancers"
+	fakePools         = "pools"
+	fakeMonitors      = "monitors"
+)
+
+// fakeCloudflare is an in memory cloudflare API serving the load balancer, pool and monitor endpoints
+// the controller uses, recording every request
+type fakeCloudflare struct {
+	mu        sync.Mutex
+	resources map[string][]map[string]any
+	nextID    int
+
+	// failures maps "<method> <id>" to the status code returned instead of handling the request
+	failures map[string]int
+	// requests holds "<method> <kind>" or "<method> <kind> <id>" of every request in order
+	requests []string
+}
+
+// newFakeCloudflare starts a fake cloudflare API and returns a client using it
+func newFakeCloudflare(t *testing.T) (*fakeCloudflare, *cloudflareClient.CloudflareAPI) {
+	t.Helper()
+
+	f := &fakeCloudflare{
+		resources: map[string][]map[string]any{},
+		failures:  map[string]int{},
+	}
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	client, err := cloudflare.NewWithAPIToken("token",
+		cloudflare.BaseURL(server.URL),
+		cloudflare.UsingRetryPolicy(0, 0, 0),
+		cloudflare.UsingRateLimit(1000))
+	if err != nil {
+		t.Fatalf("creating cloudflare client: %v", err)
+	}
+
+	return f, &cloudflareClient.CloudflareAPI{
+		CloudflareClient: client,
+		AccountId:        "account",
+		ZoneId:           "zone",
+	}
+}
+
+// add stores a resource of the kind, assigning an ID when it has none, and returns its ID
+func (f *fakeCloudflare) add(t *testing.T, kind string, resource any) string {
+	t.Helper()
+
+	data, err := json.Marshal(resource)
+	if err != nil {
+		t.Fatalf("encoding %s: %v", kind, err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("decoding %s: %v", kind, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.store(kind, fields)
+}
+
+// ids returns the IDs of the stored resources of the kind
+func (f *fakeCloudflare) ids(kind string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ids []string
+	for _, resource := range f.resources[kind] {
+		ids = append(ids, resource["id"].(string))
+	}
+
+	return ids
+}
+
+// requested returns the recorded requests of the method
+func (f *fakeCloudflare) requested(method string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var requests []string
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, method+" ") {
+			requests = append(requests, request)
+		}
+	}
+
+	return requests
+}
+
+func (f *fakeCloudflare) store(kind string, fields map[string]any) string {
+	id, _ := fields["id"].(string)
+	if id == "" {
+		f.nextID++
+		id = fmt.Sprintf("%s-%d", strings.TrimSuffix(kind, "s"), f.nextID)
+		fields["id"] = id
+	}
+
+	f.resources[kind] = append(f.resources[kind], fields)
+
+	return id
+}
+
+func (f *fakeCloudflare) find(kind string, id string) int {
+	for i, resource := range f.resources[kind] {
+		if resource["id"] == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	kind, id, ok := fakeCloudflareRoute(r.URL.Path)
+	if !ok {
+		writeFakeCloudflareError(w, http.StatusNotFound)
+		return
+	}
+
+	f.requests = append(f.requests, strings.TrimSpace(r.Method+" "+kind+" "+id))
+
+	if status, ok := f.failures[r.Method+" "+id]; ok {
+		writeFakeCloudflareError(w, status)
+		return
+	}
+
+	var body map[string]any
+	if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeFakeCloudflareError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			writeFakeCloudflareResult(w, f.resources[kind])
+		case http.MethodPost:
+			delete(body, "id")
+			f.store(kind, body)
+			writeFakeCloudflareResult(w, body)
+		default:
+			writeFakeCloudflareError(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	i := f.find(kind, id)
+	if i < 0 {
+		writeFakeCloudflareError(w, http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeFakeCloudflareResult(w, f.resources[kind][i])
+	case http.MethodPut:
+		body["id"] = id
+		f.resources[kind][i] = body
+		writeFakeCloudflareResult(w, body)
+	case http.MethodPatch:
+		for key, value := range body {
+			f.resources[kind][i][key] = value
+		}
+		writeFakeCloudflareResult(w, f.resources[kind][i])
+	case http.MethodDelete:
+		f.resources[kind] = append(f.resources[kind][:i], f.resources[kind][i+1:]...)
+		writeFakeCloudflareResult(w, map[string]any{"id": id})
+	default:
+		writeFakeCloudflareError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// fakeCloudflareRoute returns the kind and ID of the resource addressed by a path
+func fakeCloudflareRoute(path string) (string, string, bool) {
+	for prefix, kind := range map[string]string{
+		"/zones/zone/load_balancers":                fakeLoadBalancers,
+		"/accounts/account/load_balancers/pools":    fakePools,
+		"/accounts/account/load_balancers/monitors": fakeMonitors,
+	} {
+		if path == prefix {
+			return kind, "", true
+		}
+
+		if id, ok := strings.CutPrefix(path, prefix+"/"); ok && !strings.Contains(id, "/") {
+			return kind, id, true
+		}
+	}
+
+	return "", "", false
+}
+
+func writeFakeCloudflareResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"success":  true,
+		"errors":   []any{},
+		"messages": []any{},
+		"result":   result,
+	})
+}
+
+func writeFakeCloudflareError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"success":  false,
+		"errors":   []any{map[string]any{"code": 1000, "message": http.StatusText(status)}},
+		"messages": []any{},
+		"result":   nil,
+	})
+}
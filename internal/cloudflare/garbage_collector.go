@@ -0,0 +1,231 @@
+package cloudflare
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/internal/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// garbageCollector deletes the load balancers, pools and monitors owned by this cluster whose service no longer
+// uses them, e.g when the service was deleted while the controller was down or its hostname changed
+type garbageCollector struct {
+	loadBalancers *loadBalancers
+	lbOps         *LoadBalancerOps
+	cfg           config.GarbageCollectorConfiguration
+
+	// orphanedSince remembers when a resource, keyed by ID, was first seen orphaned
+	orphanedSince map[string]time.Time
+}
+
+// orphanedResource is a load balancer, pool or monitor without a service using it
+type orphanedResource struct {
+	kind        string
+	id          string
+	name        string
+	description string
+	delete      func(ctx context.Context) error
+}
+
+func newGarbageCollector(loadBalancers *loadBalancers, lbOps *LoadBalancerOps, cfg config.GarbageCollectorConfiguration) *garbageCollector {
+	return &garbageCollector{
+		loadBalancers: loadBalancers,
+		lbOps:         lbOps,
+		cfg:           cfg,
+		orphanedSince: map[string]time.Time{},
+	}
+}
+
+// Run collects orphans every interval until stop is closed
+func (c *garbageCollector) Run(stop <-chan struct{}) {
+	if !cache.WaitForCacheSync(stop, c.lbOps.hasSynced) {
+		klog.Error("Failed to sync caches for the garbage collector")
+		return
+	}
+
+	klog.Info("Starting garbage collector with interval ", c.cfg.Interval, " and grace period ", c.cfg.GracePeriod, ", report only: ", c.cfg.ReportOnly)
+
+	wait.Until(func() {
+		err := c.collect(context.Background())
+		if err != nil {
+			klog.Warning("Failed to collect orphaned cloudflare resources: ", err)
+		}
+	}, c.cfg.Interval, stop)
+}
+
+// collect deletes the orphans that exceeded the grace period. Load balancers are deleted before pools and
+// pools before monitors, as cloudflare refuses to delete resources that are still referenced
+func (c *garbageCollector) collect(ctx context.Context) error {
+	orphans, err := c.findOrphans(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	seen := map[string]bool{}
+
+	for _, orphan := range orphans {
+		seen[orphan.id] = true
+
+		since, ok := c.orphanedSince[orphan.id]
+		if !ok {
+			klog.Info("Found orphaned ", orphan.kind, " ", orphan.name, " (", orphan.description, ")")
+			c.orphanedSince[orphan.id] = now
+			continue
+		}
+
+		if now.Sub(since) < c.cfg.GracePeriod {
+			continue
+		}
+
+		if c.cfg.ReportOnly {
+			klog.Info("Orphaned ", orphan.kind, " ", orphan.name, " would be deleted, orphaned since ", since)
+			continue
+		}
+
+		err := orphan.delete(ctx)
+		if err != nil {
+			klog.Warning("Failed to delete orphaned ", orphan.kind, " ", orphan.name, ": ", err)
+			continue
+		}
+
+		klog.Info("Deleted orphaned ", orphan.kind, " ", orphan.name)
+		delete(c.orphanedSince, orphan.id)
+	}
+
+	// Forget resources that were adopted again or deleted by someone else
+	for id := range c.orphanedSince {
+		if !seen[id] {
+			delete(c.orphanedSince, id)
+		}
+	}
+
+	return nil
+}
+
+// findOrphans lists the resources owned by this cluster that no service uses, in deletion order
+func (c *garbageCollector) findOrphans(ctx context.Context) ([]orphanedResource, error) {
+	client := c.loadBalancers.client
+
+	loadBalancers, err := client.ListLoadBalancers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pools, err := client.ListLoadBalancerPools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	monitors, err := client.ListLoadBalancerMonitors(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []orphanedResource
+
+	for _, loadBalancer := range loadBalancers {
+		id := loadBalancer.ID
+		if c.isOrphan(loadBalancer.Description, func(target LoadBalancerTarget) bool {
+			return loadBalancer.Name == client.FormatResourceName(target.HostName)
+		}) {
+			orphans = append(orphans, orphanedResource{
+				kind:        "load balancer",
+				id:          id,
+				name:        loadBalancer.Name,
+				description: loadBalancer.Description,
+				delete: func(ctx context.Context) error {
+					return client.DeleteLoadBalancerByID(ctx, id)
+				},
+			})
+		}
+	}
+
+	for _, pool := range pools {
+		id := pool.ID
+		if c.isOrphan(pool.Description, func(target LoadBalancerTarget) bool {
+			return c.loadBalancers.isLoadBalancerPoolName(target.HostName, pool.Name)
+		}) {
+			orphans = append(orphans, orphanedResource{
+				kind:        "pool",
+				id:          id,
+				name:        pool.Name,
+				description: pool.Description,
+				delete: func(ctx context.Context) error {
+					return client.DeleteLoadBalancerPoolByID(ctx, id)
+				},
+			})
+		}
+	}
+
+	for _, monitor := range monitors {
+		id := monitor.ID
+		name, _, _ := strings.Cut(monitor.Description, " ")
+		if c.isOrphan(monitor.Description, func(target LoadBalancerTarget) bool {
+			return name == c.loadBalancers.getLoadBalancerMonitorName(target.HostName)
+		}) {
+			orphans = append(orphans, orphanedResource{
+				kind:        "monitor",
+				id:          id,
+				name:        name,
+				description: monitor.Description,
+				delete: func(ctx context.Context) error {
+					return client.DeleteLoadBalancerMonitorByID(ctx, id)
+				},
+			})
+		}
+	}
+
+	return orphans, nil
+}
+
+// isOrphan reports whether a resource is owned by this cluster but its service no longer exists, isn't
+// a load balancer managed by this controller or none of its hostnames uses the resource
+func (c *garbageCollector) isOrphan(description string, usedBy func(target LoadBalancerTarget) bool) bool {
+	fields := parseOwnershipDescription(description)
+	if fields == nil || fields[ownershipCluster] != c.lbOps.clusterID() {
+		return false
+	}
+
+	namespace, name, ok := strings.Cut(fields[ownershipService], "/")
+	if !ok {
+		return false
+	}
+
+	service, err := c.lbOps.serviceLister.Services(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return true
+	}
+
+	if err != nil {
+		// Keep the resource when in doubt
+		return false
+	}
+
+	// The UID isn't compared. A recreated service of the same name takes the resource over when one of its
+	// hostnames still uses it, otherwise the resource is orphaned just like when the service is gone
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || service.Spec.LoadBalancerClass != nil {
+		return true
+	}
+
+	targets, err := GetLoadBalancerTargets(service)
+	if err != nil {
+		// A missing hostname annotation means the service is no longer managed, other errors may be fixed by the user
+		_, hostNameErr := GetLoadBalancerHostName(service)
+		return hostNameErr != nil
+	}
+
+	for _, target := range targets {
+		if usedBy(target) {
+			return false
+		}
+	}
+
+	return true
+}
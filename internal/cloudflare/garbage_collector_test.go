@@ -0,0 +1,132 @@
+package cloudflare
+
+import (
+	"testing"
+
+	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/internal/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestIsOrphan(t *testing.T) {
+	loadBalancerClass := "example.com/other"
+
+	withUID := func(service *v1.Service, uid types.UID) *v1.Service {
+		service.UID = uid
+		return service
+	}
+
+	tests := []struct {
+		name        string
+		service     *v1.Service
+		description string
+		used        bool
+		want        bool
+	}{
+		{
+			name:        "service in use",
+			service:     withUID(newTestService(nil, testPortHTTP), "uid-1"),
+			description: testMarker,
+			used:        true,
+		},
+		{
+			name:        "service gone",
+			description: testMarker,
+			want:        true,
+		},
+		{
+			name:        "hostname no longer used",
+			service:     withUID(newTestService(nil, testPortHTTP), "uid-1"),
+			description: testMarker,
+			want:        true,
+		},
+		{
+			name:        "recreated service using the resource",
+			service:     withUID(newTestService(nil, testPortHTTP), "uid-2"),
+			description: testMarker,
+			used:        true,
+		},
+		{
+			name:        "recreated service not using the resource",
+			service:     withUID(newTestService(nil, testPortHTTP), "uid-2"),
+			description: testMarker,
+			want:        true,
+		},
+		{
+			name: "service no longer a load balancer",
+			service: func() *v1.Service {
+				service := withUID(newTestService(nil, testPortHTTP), "uid-1")
+				service.Spec.Type = v1.ServiceTypeClusterIP
+				return service
+			}(),
+			description: testMarker,
+			used:        true,
+			want:        true,
+		},
+		{
+			name: "service of another load balancer class",
+			service: func() *v1.Service {
+				service := withUID(newTestService(nil, testPortHTTP), "uid-1")
+				service.Spec.LoadBalancerClass = &loadBalancerClass
+				return service
+			}(),
+			description: testMarker,
+			used:        true,
+			want:        true,
+		},
+		{
+			name: "hostname annotation removed",
+			service: func() *v1.Service {
+				service := withUID(newTestService(nil, testPortHTTP), "uid-1")
+				delete(service.Annotations, serviceAnnotationLoadBalancerHostName)
+				return service
+			}(),
+			description: testMarker,
+			want:        true,
+		},
+		{
+			name:        "invalid annotation keeps the resource",
+			service:     withUID(newTestService(map[string]string{serviceAnnotationLoadBalancerPortHostNames: "http"}, testPortHTTP), "uid-1"),
+			description: testMarker,
+		},
+		{
+			name:        "other cluster",
+			description: testMarkerOtherCluster,
+		},
+		{
+			name:        "unmarked",
+			description: "",
+		},
+		{
+			name:        "marker of another controller",
+			description: testMarkerOtherProvider,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			if test.service != nil {
+				if err := indexer.Add(test.service); err != nil {
+					t.Fatalf("adding service: %v", err)
+				}
+			}
+
+			c := &garbageCollector{
+				lbOps: &LoadBalancerOps{
+					defaults:      config.LoadBalancerConfiguration{ClusterID: "prod"},
+					serviceLister: corelisters.NewServiceLister(indexer),
+				},
+			}
+
+			got := c.isOrphan(test.description, func(target LoadBalancerTarget) bool {
+				return test.used && target.HostName == "example.com"
+			})
+			if got != test.want {
+				t.Errorf("isOrphan() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,24 @@ package cloudflare
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
 
+	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/internal/config"
 	cloudflareClient "github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/pkg/cloudflare"
 	"github.com/cloudflare/cloudflare-go"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 )
 
@@ -15,7 +28,22 @@ type loadBalancers struct {
 	lbOps  *LoadBalancerOps
 }
 
+// LoadBalancerOps holds the cluster wide defaults and the cluster state used to pick origins,
+// the latter is filled in once the cloud provider is initialized
 type LoadBalancerOps struct {
+	defaults            config.LoadBalancerConfiguration
+	client              kubernetes.Interface
+	endpointSliceLister discoverylisters.EndpointSliceLister
+	serviceLister       corelisters.ServiceLister
+	nodeLister          corelisters.NodeLister
+	hasSynced           cache.InformerSynced
+	recorder            record.EventRecorder
+
+	clusterNameMu sync.RWMutex
+	clusterName   string
+
+	serviceLocksMu sync.Mutex
+	serviceLocks   map[string]*serviceLock
 }
 
 func newLoadbalancers(client *cloudflareClient.CloudflareAPI, lbOps *LoadBalancerOps) *loadBalancers {
@@ -42,15 +70,32 @@ func (l *loadBalancers) GetLoadBalancer(ctx context.Context, clusterName string,
 
 	_, err = l.client.GetLoadBalancer(ctx, hostName)
 
+	if cloudflareClient.IsNotFound(err) {
+		return nil, false, nil
+	}
+
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to get load balancer by host name: %v", err)
+		return nil, false, fmt.Errorf("failed to get load balancer by host name: %w", err)
 	}
 
-	status := &v1.LoadBalancerStatus{
-		Ingress: []v1.LoadBalancerIngress{{Hostname: hostName}},
+	targets, err := GetLoadBalancerTargets(service)
+	if err != nil {
+		// Invalid per port annotations are reported by EnsureLoadBalancer, only report the hostname annotation
+		targets = []LoadBalancerTarget{{HostName: hostName}}
 	}
 
-	return status, true, nil
+	return loadBalancerStatus(targets), true, nil
+}
+
+// loadBalancerStatus returns the status listing the hostnames of every load balancer of the service
+func loadBalancerStatus(targets []LoadBalancerTarget) *v1.LoadBalancerStatus {
+	status := &v1.LoadBalancerStatus{}
+
+	for _, target := range targets {
+		status.Ingress = append(status.Ingress, v1.LoadBalancerIngress{Hostname: target.HostName})
+	}
+
+	return status
 }
 
 // GetLoadBalancerName returns the name of the load balancer. Implementations must treat the
@@ -70,6 +115,7 @@ func (l *loadBalancers) GetLoadBalancerName(ctx context.Context, clusterName str
 // parameters as read-only and not modify them.
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager
 func (l *loadBalancers) EnsureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	l.lbOps.observeClusterName(clusterName)
 
 	// Get Annotations
 	_, err := GetLoadBalancerHostName(service)
@@ -79,34 +125,65 @@ func (l *loadBalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 		return &v1.LoadBalancerStatus{}, nil
 	}
 
-	// Verify LB monitor exists if not create
-	monitor, err := l.createLoadBalancerMonitorIfNotExist(ctx, service)
+	unlock := l.lbOps.lockService(service.Namespace, service.Name)
+	defer unlock()
+
+	cfg, err := l.parseLoadBalancerConfig(service)
 	if err != nil {
 		return nil, err
 	}
 
+	// Resources created by a failed attempt are deleted so retries don't pile them up
+	created := &createdResources{}
+
+	for _, target := range cfg.Targets {
+		err := l.ensureLoadBalancerTarget(ctx, service, cfg, target, nodes, created)
+		if err != nil {
+			created.rollback(ctx, l.client)
+			return nil, err
+		}
+	}
+
+	// Hostnames without a load balancer marked for the service got one now, announce them before the resources
+	// of previous hostnames are deleted
+	for _, hostName := range created.loadBalancerHostNames {
+		l.lbOps.recordNormal(service, eventReasonHostnameProvisioned, fmt.Sprintf("Created load balancer for hostname %s", hostName))
+	}
+
+	// The new hostnames serve traffic now, so the resources of previous ones can go
+	l.migrateLoadBalancerHostNames(ctx, service, cfg)
+
+	return loadBalancerStatus(cfg.Targets), nil
+}
+
+// ensureLoadBalancerTarget creates or updates the monitor, pools and load balancer of a single hostname of the service
+func (l *loadBalancers) ensureLoadBalancerTarget(ctx context.Context, service *v1.Service, cfg LoadBalancerConfig, target LoadBalancerTarget, nodes []*v1.Node, created *createdResources) error {
+
+	// Verify LB monitor exists if not create
+	monitor, err := l.createLoadBalancerMonitorIfNotExist(ctx, service, cfg, target, created)
+	if err != nil {
+		return err
+	}
+
 	klog.Info("Verified monitor exists on cloudflare")
 
-	// Verify LB pool exists if not create
-	pool, err := l.createLoadBalancerPoolIfNotExist(ctx, monitor, service, nodes)
+	// Verify LB pools exist if not create
+	pools, err := l.ensureLoadBalancerPools(ctx, monitor, service, cfg, target, nodes, created)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	klog.Info("Verified pool exists on cloudflare")
+	klog.Info("Verified ", len(pools), " pools exist on cloudflare")
 
-	// Verify LB pool exists if not create
-	loadBalancer, err := l.createLoadBalancerIfNotExist(ctx, pool, service)
+	// Verify LB exists if not create
+	loadBalancer, err := l.createLoadBalancerIfNotExist(ctx, pools, service, cfg, target, created)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	klog.Info("Verified loadBalancer exists on cloudflare with hostname: ", loadBalancer.Name)
 
-	status := &v1.LoadBalancerStatus{
-		Ingress: []v1.LoadBalancerIngress{{Hostname: loadBalancer.Name}},
-	}
-	return status, nil
+	return nil
 }
 
 // UpdateLoadBalancer updates hosts under the specified load balancer.
@@ -114,6 +191,7 @@ func (l *loadBalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 // parameters as read-only and not modify them.
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager
 func (l *loadBalancers) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
+	l.lbOps.observeClusterName(clusterName)
 
 	_, err := GetLoadBalancerHostName(service)
 	if err != nil {
@@ -122,13 +200,53 @@ func (l *loadBalancers) UpdateLoadBalancer(ctx context.Context, clusterName stri
 		return nil
 	}
 
+	unlock := l.lbOps.lockService(service.Namespace, service.Name)
+	defer unlock()
+
+	return l.updateLoadBalancer(ctx, service, nodes)
+}
+
+// updateLoadBalancer updates the monitors and pools of every hostname of the service. The caller must hold the service lock
+func (l *loadBalancers) updateLoadBalancer(ctx context.Context, service *v1.Service, nodes []*v1.Node) error {
+
+	cfg, err := l.parseLoadBalancerConfig(service)
+	if err != nil {
+		return err
+	}
+
+	created := &createdResources{}
+
+	for _, target := range cfg.Targets {
+		err := l.updateLoadBalancerTarget(ctx, service, cfg, target, nodes, created)
+		if err != nil {
+			created.rollback(ctx, l.client)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateLoadBalancerTarget updates the monitor and pools of a single hostname of the service
+func (l *loadBalancers) updateLoadBalancerTarget(ctx context.Context, service *v1.Service, cfg LoadBalancerConfig, target LoadBalancerTarget, nodes []*v1.Node, created *createdResources) error {
+
 	// Verify LB monitor exists if not create
-	monitor, err := l.createLoadBalancerMonitorIfNotExist(ctx, service)
+	monitor, err := l.createLoadBalancerMonitorIfNotExist(ctx, service, cfg, target, created)
 	if err != nil {
 		return err
 	}
 
-	_, err = l.updateLoadBalancerPool(ctx, monitor, service, nodes)
+	pools, err := l.ensureLoadBalancerPools(ctx, monitor, service, cfg, target, nodes, created)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.PoolPartition {
+		return nil
+	}
+
+	// Node changes can add or remove partitions, so the pools of the load balancer need updating too
+	_, err = l.createLoadBalancerIfNotExist(ctx, pools, service, cfg, target, created)
 
 	return err
 }
@@ -139,6 +257,7 @@ func (l *loadBalancers) UpdateLoadBalancer(ctx context.Context, clusterName stri
 // Implementations must treat the *v1.Service parameter as read-only and not modify it.
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager
 func (l *loadBalancers) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
+	l.lbOps.observeClusterName(clusterName)
 	if service.Spec.LoadBalancerClass != nil {
 		return nil
 	}
@@ -151,224 +270,696 @@ func (l *loadBalancers) EnsureLoadBalancerDeleted(ctx context.Context, clusterNa
 		return nil
 	}
 
+	unlock := l.lbOps.lockService(service.Namespace, service.Name)
+	defer unlock()
+
 	return l.deleteLoadBalancer(ctx, service)
 }
 
-func (l *loadBalancers) getLoadBalancerPoolName(service *v1.Service) (string, error) {
-	hostName, err := GetLoadBalancerHostName(service)
+// parseLoadBalancerConfig parses the annotations of the service, recording every invalid
+// annotation as a warning event on the service
+func (l *loadBalancers) parseLoadBalancerConfig(service *v1.Service) (LoadBalancerConfig, error) {
+	cfg, err := ParseLoadBalancerConfig(service, l.lbOps.defaults)
+	if err == nil {
+		return cfg, nil
+	}
 
-	if err != nil {
-		return "", fmt.Errorf("failed to get load balancer host name: %v", err)
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
 	}
 
-	return l.client.FormatResourceName(hostName + "-pool"), nil
+	for _, annotationErr := range errs {
+		l.lbOps.recordWarning(service, eventReasonInvalidAnnotation, annotationErr.Error())
+	}
+
+	return LoadBalancerConfig{}, fmt.Errorf("invalid load balancer annotations: %w", err)
 }
 
-func (l *loadBalancers) getLoadBalancerMonitorName(service *v1.Service) (string, error) {
-	hostName, err := GetLoadBalancerHostName(service)
+func (l *loadBalancers) getLoadBalancerPoolName(hostName string) string {
+	return l.getLoadBalancerPartitionPoolName(hostName, "")
+}
 
-	if err != nil {
-		return "", fmt.Errorf("failed to get load balancer host name: %v", err)
+// getLoadBalancerPartitionPoolName returns the name of the pool serving a partition, the empty
+// partition being the single pool of a service that isn't partitioned
+func (l *loadBalancers) getLoadBalancerPartitionPoolName(hostName string, partition string) string {
+	if partition == "" {
+		return l.client.FormatResourceName(hostName + "-pool")
 	}
 
-	return l.client.FormatResourceName(hostName + "-monitor"), nil
+	return l.client.FormatResourceName(hostName + "-" + partition + "-pool")
+}
+
+// isLoadBalancerPoolName reports whether a pool name follows the naming of the pools of the hostname
+func (l *loadBalancers) isLoadBalancerPoolName(hostName string, poolName string) bool {
+	return strings.HasPrefix(poolName, l.client.FormatResourceName(hostName+"-")) && strings.HasSuffix(poolName, "-pool")
+}
+
+func (l *loadBalancers) getLoadBalancerMonitorName(hostName string) string {
+	return l.client.FormatResourceName(hostName + "-monitor")
 }
 
 // createLoadBalancerMonitorIfNotExist will check with the cloudflare API that the monitor exists
-// if not it will create a new one using the service config
-func (l *loadBalancers) createLoadBalancerMonitorIfNotExist(ctx context.Context, service *v1.Service) (cloudflare.LoadBalancerMonitor, error) {
+// if not it will create a new one using the service config. An existing monitor is updated
+// when it no longer matches the service config
+func (l *loadBalancers) createLoadBalancerMonitorIfNotExist(ctx context.Context, service *v1.Service, cfg LoadBalancerConfig, target LoadBalancerTarget, created *createdResources) (cloudflare.LoadBalancerMonitor, error) {
+
+	monitorName := l.getLoadBalancerMonitorName(target.HostName)
+	desired, err := l.buildLoadBalancerMonitor(ctx, monitorName, service, cfg.Monitor, target.Port)
+	if err != nil {
+		return cloudflare.LoadBalancerMonitor{}, err
+	}
 
-	monitorName, _ := l.getLoadBalancerMonitorName(service) // Ignore err as it has already been checked
 	monitor, err := l.client.GetLoadBalancerMonitor(ctx, monitorName)
 
-	if err != nil {
+	if cloudflareClient.IsNotFound(err) {
 
 		klog.Info("Creating LB monitor")
 
-		monitorPath, _ := GetLoadBalancerMonitorPath(service)
-		monitorAllowInsecure, _ := GetLoadBalancerMonitorAllowInsecure(service)
-		monitorType, _ := GetLoadBalancerMonitorType(service)
-		probeZone, _ := GetLoadBalancerMonitorProbeZone(service)
-		header, _ := GetLoadBalancerMonitorHeader(service)
-
 		// Try creating a new load balancer monitor
-		monitor, err = l.client.CreateLoadBalancerMonitor(ctx, cloudflare.LoadBalancerMonitor{
-			Description:     monitorName,
-			Type:            monitorType,
-			Method:          "GET",
-			Path:            monitorPath,
-			Port:            uint16(service.Spec.Ports[0].Port), //TODO get additional ports
-			ExpectedCodes:   "2xx",
-			Interval:        60,
-			Timeout:         5,
-			Retries:         2,
-			FollowRedirects: true,
-			AllowInsecure:   monitorAllowInsecure,
-			ProbeZone:       probeZone,
-			Header: map[string][]string{
-				"Host": header,
-			},
-		})
+		monitor, err = l.client.CreateLoadBalancerMonitor(ctx, desired)
+		if err != nil {
+			return cloudflare.LoadBalancerMonitor{}, err
+		}
+
+		created.addMonitor(monitor.ID)
 
-		return monitor, err
+		return monitor, nil
+	}
+
+	if err != nil {
+		return cloudflare.LoadBalancerMonitor{}, err
+	}
+
+	if err := l.lbOps.checkOwnership(service, "monitor", monitorName, monitor.Description); err != nil {
+		return cloudflare.LoadBalancerMonitor{}, err
+	}
+
+	if loadBalancerMonitorEqual(monitor, desired) {
+		return monitor, nil
+	}
+
+	klog.Info("Updating LB monitor: ", monitorName)
+
+	desired.ID = monitor.ID
+
+	return l.client.UpdateLoadBalancerMonitor(ctx, desired)
+}
+
+// buildLoadBalancerMonitor returns the monitor described by the service config probing the given port
+func (l *loadBalancers) buildLoadBalancerMonitor(ctx context.Context, monitorName string, service *v1.Service, cfg LoadBalancerMonitorConfig, port *v1.ServicePort) (cloudflare.LoadBalancerMonitor, error) {
+
+	monitor := cloudflare.LoadBalancerMonitor{
+		// Monitors have no name, so it is prepended to the ownership marker
+		Description:     monitorName + " " + l.lbOps.ownershipDescription(service),
+		Interval:        cfg.Interval,
+		Timeout:         cfg.Timeout,
+		Retries:         cfg.Retries,
+		ConsecutiveUp:   cfg.ConsecutiveUp,
+		ConsecutiveDown: cfg.ConsecutiveDown,
+	}
+
+	if cfg.Mode == monitorModeHealthCheckNodePort {
+		// kube-proxy answers with 200 on nodes hosting ready endpoints and 503 on all others
+		monitor.Type = "http"
+		monitor.Method = "GET"
+		monitor.Path = kubeProxyHealthCheckPath
+		monitor.Port = uint16(service.Spec.HealthCheckNodePort)
+		monitor.ExpectedCodes = "200"
+
+		return monitor, nil
+	}
+
+	if port == nil {
+		return cloudflare.LoadBalancerMonitor{}, fmt.Errorf("monitor %s has no service port to probe", monitorName)
+	}
+
+	monitor.Type = cfg.Type
+	monitor.Method = cfg.Method
+	monitor.Port = uint16(port.Port)
+	monitor.AllowInsecure = cfg.AllowInsecure
+	monitor.ProbeZone = cfg.ProbeZone
+
+	// HTTP specific settings are rejected for TCP monitors
+	if monitor.Type == "tcp" {
+		return monitor, nil
+	}
+
+	monitor.Path = cfg.Path
+	monitor.ExpectedCodes = cfg.ExpectedCodes
+	monitor.ExpectedBody = cfg.ExpectedBody
+	monitor.FollowRedirects = cfg.FollowRedirects
+	monitor.Header = maps.Clone(cfg.Headers)
+
+	secretHeaders, err := l.lbOps.secretHeaders(ctx, service, cfg.HeadersSecret, cfg.HeadersSecretKeys)
+	if err != nil {
+		return cloudflare.LoadBalancerMonitor{}, err
+	}
+
+	for name, values := range secretHeaders {
+		if _, ok := monitor.Header[name]; ok {
+			return cloudflare.LoadBalancerMonitor{}, fmt.Errorf("header %s is set by both %s and secret %s", name, serviceAnnotationLoadBalancerMonitorHeaders, cfg.HeadersSecret)
+		}
+
+		monitor.Header[name] = values
 	}
 
 	return monitor, nil
 }
 
-// createLoadBalancerPoolIfNotExist will check with the cloudflare API that the pool exists
-// if not it will create a new one using the service config
-func (l *loadBalancers) createLoadBalancerPoolIfNotExist(ctx context.Context, monitor cloudflare.LoadBalancerMonitor, service *v1.Service, nodes []*v1.Node) (cloudflare.LoadBalancerPool, error) {
+// secretHeaders returns the health check headers stored under the given keys of the secret referenced by the service
+func (o *LoadBalancerOps) secretHeaders(ctx context.Context, service *v1.Service, secretName string, keys []string) (map[string][]string, error) {
 
-	poolName, _ := l.getLoadBalancerPoolName(service) // Ignore err as it has already been checked
-	_, err := l.client.GetLoadBalancerPool(ctx, poolName)
+	if secretName == "" {
+		return nil, nil
+	}
 
+	if o.client == nil {
+		return nil, fmt.Errorf("cannot read secret %s before the cloud provider is initialized", secretName)
+	}
+
+	// Secrets are only read from the namespace of the service so services can't expose secrets of other namespaces
+	secret, err := o.client.CoreV1().Secrets(service.Namespace).Get(ctx, secretName, metav1.GetOptions{})
 	if err != nil {
+		return nil, fmt.Errorf("failed to get monitor headers secret %s/%s: %w", service.Namespace, secretName, err)
+	}
 
-		klog.Info("LB Pool does not exist - creating a new pool")
+	// Secrets have to opt in so a service can't send any secret of its namespace to cloudflare
+	if secret.Labels[secretLabelMonitorHeaders] != "true" {
+		return nil, fmt.Errorf("monitor headers secret %s/%s must be labelled %s=true", service.Namespace, secretName, secretLabelMonitorHeaders)
+	}
 
-		config := cloudflare.LoadBalancerPool{
-			Name:    poolName,
-			Monitor: monitor.ID,
-			Origins: []cloudflare.LoadBalancerOrigin{},
-			Enabled: true,
+	headers := map[string][]string{}
+	for _, key := range keys {
+		value, ok := secret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("monitor headers secret %s/%s has no key %s", service.Namespace, secretName, key)
 		}
 
-		for _, node := range nodes {
+		headers[http.CanonicalHeaderKey(key)] = []string{string(value)}
+	}
 
-			ip, err := GetNodeExternalIP(node)
+	return headers, nil
+}
 
-			if err != nil {
-				return cloudflare.LoadBalancerPool{}, err
-			}
+// ensureLoadBalancerPools will create or update a pool of the target for every partition of the nodes
+// and returns them in priority order
+func (l *loadBalancers) ensureLoadBalancerPools(ctx context.Context, monitor cloudflare.LoadBalancerMonitor, service *v1.Service, cfg LoadBalancerConfig, target LoadBalancerTarget, nodes []*v1.Node, created *createdResources) ([]cloudflare.LoadBalancerPool, error) {
 
-			origin := cloudflare.LoadBalancerOrigin{
-				Name:    l.client.FormatResourceName(ip),
-				Address: ip,
-				Enabled: true,
-				Weight:  1,
-			}
+	nodes = eligibleOriginNodes(cfg, nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no ready nodes eligible as origins for service %s/%s", service.Namespace, service.Name)
+	}
 
-			config.Origins = append(config.Origins, origin)
+	nodes, err := l.lbOps.selectOriginNodes(service, cfg, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, weights, err := l.lbOps.localEndpointNodes(service, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Weights are recomputed on every update so they follow node capacity changes
+	weights = mergeOriginWeights(weights, originCapacityWeights(cfg, nodes))
+
+	partitions, err := partitionNodes(cfg, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]cloudflare.LoadBalancerPool, 0, len(partitions))
+
+	for _, partition := range partitions {
+		poolName := l.getLoadBalancerPartitionPoolName(target.HostName, partition.value)
+
+		pool, err := l.createLoadBalancerPoolIfNotExist(ctx, monitor, poolName, service, cfg, partition.nodes, weights, created)
+		if err != nil {
+			return nil, err
+		}
+
+		pools = append(pools, pool)
+	}
+
+	return pools, nil
+}
+
+// createLoadBalancerPoolIfNotExist will check with the cloudflare API that the pool exists
+// if not it will create a new one using the service config
+func (l *loadBalancers) createLoadBalancerPoolIfNotExist(ctx context.Context, monitor cloudflare.LoadBalancerMonitor, poolName string, service *v1.Service, cfg LoadBalancerConfig, nodes []*v1.Node, weights map[string]float64, created *createdResources) (cloudflare.LoadBalancerPool, error) {
+
+	_, err := l.client.GetLoadBalancerPool(ctx, poolName)
+
+	if cloudflareClient.IsNotFound(err) {
+
+		klog.Info("LB Pool does not exist - creating a new pool")
+
+		config := buildLoadBalancerPool(cloudflare.LoadBalancerPool{Name: poolName}, monitor, cfg, l.lbOps.ownershipDescription(service))
+
+		config.Origins, err = l.buildLoadBalancerPoolOrigins(nodes, cfg, weights)
+		if err != nil {
+			return cloudflare.LoadBalancerPool{}, err
 		}
 
 		// Try creating a new load balancer pool
 		pool, err := l.client.CreateLoadBalancerPool(ctx, config)
+		if err != nil {
+			return cloudflare.LoadBalancerPool{}, err
+		}
+
+		created.addPool(pool.ID)
+
+		return pool, l.ensureLoadBalancerPoolNotificationFilter(ctx, pool, cfg.PoolNotificationFilter)
+	}
 
-		return pool, err
+	if err != nil {
+		return cloudflare.LoadBalancerPool{}, err
 	}
 
-	return l.updateLoadBalancerPool(ctx, monitor, service, nodes)
+	return l.updateLoadBalancerPool(ctx, monitor, poolName, service, cfg, nodes, weights)
 }
 
-// createLoadBalancerPoolIfNotExist will check with the cloudflare API that the pool exists
-// if not it will create a new one using the service config
-func (l *loadBalancers) updateLoadBalancerPool(ctx context.Context, monitor cloudflare.LoadBalancerMonitor, service *v1.Service, nodes []*v1.Node) (cloudflare.LoadBalancerPool, error) {
+// updateLoadBalancerPool will replace the origins of an existing pool with the given nodes
+// and reapply the pool settings of the service config
+func (l *loadBalancers) updateLoadBalancerPool(ctx context.Context, monitor cloudflare.LoadBalancerMonitor, poolName string, service *v1.Service, cfg LoadBalancerConfig, nodes []*v1.Node, weights map[string]float64) (cloudflare.LoadBalancerPool, error) {
 
-	klog.Info("Trying to update load balancer pool for ", len(nodes), " nodes")
+	klog.V(4).Info("Trying to update load balancer pool ", poolName, " for ", len(nodes), " nodes")
 
-	poolName, _ := l.getLoadBalancerPoolName(service) // Ignore err as it has already been checked
 	pool, err := l.client.GetLoadBalancerPool(ctx, poolName)
 
 	if err != nil {
 		return cloudflare.LoadBalancerPool{}, err
 	}
 
-	config := cloudflare.LoadBalancerPool{
-		ID:      pool.ID,
-		Name:    pool.Name,
-		Monitor: monitor.ID,
-		Origins: []cloudflare.LoadBalancerOrigin{},
-		Enabled: true,
+	if err := l.lbOps.checkOwnership(service, "pool", poolName, pool.Description); err != nil {
+		return cloudflare.LoadBalancerPool{}, err
+	}
+
+	config := buildLoadBalancerPool(pool, monitor, cfg, l.lbOps.ownershipDescription(service))
+
+	config.Origins, err = l.buildLoadBalancerPoolOrigins(nodes, cfg, weights)
+	if err != nil {
+		return cloudflare.LoadBalancerPool{}, err
+	}
+
+	if loadBalancerPoolEqual(pool, config) {
+		klog.V(4).Info("LB pool ", poolName, " is up to date")
+		return pool, l.ensureLoadBalancerPoolNotificationFilter(ctx, pool, cfg.PoolNotificationFilter)
 	}
 
-	for _, node := range nodes {
+	klog.Info("Updating LB pool ", poolName, " with ", len(config.Origins), " origins")
+	klog.V(4).Info("Updating LB pool with config: ", config)
 
-		ip, err := GetNodeExternalIP(node)
+	pool, err = l.client.UpdateLoadBalancerPool(ctx, config)
+	if err != nil {
+		return cloudflare.LoadBalancerPool{}, err
+	}
 
-		if err != nil {
-			klog.Warning(err) // Likely the node doesn't have an external ip so skip but log
-			continue
-		}
+	return pool, l.ensureLoadBalancerPoolNotificationFilter(ctx, pool, cfg.PoolNotificationFilter)
+}
 
-		origin := cloudflare.LoadBalancerOrigin{
-			Name:    l.client.FormatResourceName(ip),
-			Address: ip,
-			Enabled: true,
-			Weight:  1,
-		}
+// ensureLoadBalancerPoolNotificationFilter sets the notification filter of the pool when it differs from the service config
+func (l *loadBalancers) ensureLoadBalancerPoolNotificationFilter(ctx context.Context, pool cloudflare.LoadBalancerPool, filter *cloudflareClient.LoadBalancerPoolNotificationFilter) error {
 
-		config.Origins = append(config.Origins, origin)
+	current, err := l.client.GetLoadBalancerPoolNotificationFilter(ctx, pool.ID)
+	if err != nil {
+		return err
 	}
 
-	klog.Info("Updating LB pool with config: ", config)
+	if notificationFilterEqual(current, filter) {
+		return nil
+	}
 
-	return l.client.UpdateLoadBalancerPool(ctx, config)
+	klog.Info("Updating notification filter of LB pool ", pool.Name)
 
+	return l.client.UpdateLoadBalancerPoolNotificationFilter(ctx, pool.ID, filter)
+}
+
+// buildLoadBalancerPool applies the ownership marker, the monitor and the pool settings of the service config to the pool
+// without any origins. Settings not managed by annotations, like the coordinates, are kept as they are
+func buildLoadBalancerPool(pool cloudflare.LoadBalancerPool, monitor cloudflare.LoadBalancerMonitor, cfg LoadBalancerConfig, description string) cloudflare.LoadBalancerPool {
+
+	pool.Description = description
+	pool.Monitor = monitor.ID
+	pool.Origins = []cloudflare.LoadBalancerOrigin{}
+	pool.Enabled = true
+	pool.MinimumOrigins = cfg.PoolMinimumOrigins
+	pool.LoadShedding = cfg.PoolLoadShedding
+	pool.CheckRegions = cfg.PoolCheckRegions
+	pool.NotificationEmail = cfg.PoolNotificationEmail
+	pool.OriginSteering = nil
+
+	if cfg.PoolOriginSteering != "" {
+		pool.OriginSteering = &cloudflare.LoadBalancerOriginSteering{
+			Policy: cfg.PoolOriginSteering,
+		}
+	}
+
+	return pool
 }
 
 // createLoadBalancerIfNotExist will check with the cloudflare API that the load balancer exists
-// if not it will create a new one using the service config
-func (l *loadBalancers) createLoadBalancerIfNotExist(ctx context.Context, pool cloudflare.LoadBalancerPool, service *v1.Service) (cloudflare.LoadBalancer, error) {
+// if not it will create a new one using the service config. An existing load balancer is updated
+// when it no longer matches the service config
+func (l *loadBalancers) createLoadBalancerIfNotExist(ctx context.Context, pools []cloudflare.LoadBalancerPool, service *v1.Service, cfg LoadBalancerConfig, target LoadBalancerTarget, created *createdResources) (cloudflare.LoadBalancer, error) {
+
+	hostName := target.HostName
+	desired, err := l.buildLoadBalancer(pools, cfg, hostName)
+	if err != nil {
+		return cloudflare.LoadBalancer{}, err
+	}
+
+	desired.Description = l.lbOps.ownershipDescription(service)
 
-	hostName, _ := GetLoadBalancerHostName(service) // Ignore err as it has already been checked
 	loadBalancer, err := l.client.GetLoadBalancer(ctx, hostName)
 
-	if err != nil {
+	if cloudflareClient.IsNotFound(err) {
 		klog.Info("Creating LB")
 
 		// Try creating a new load balancer
-		loadBalancer, err := l.client.CreateLoadBalancer(ctx, cloudflare.LoadBalancer{
-			Name:         l.client.FormatResourceName(hostName),
-			FallbackPool: pool.ID,
-			DefaultPools: []string{pool.ID},
-			TTL:          30,
-			Proxied:      true,
+		loadBalancer, err := l.client.CreateLoadBalancer(ctx, desired)
+		if err != nil {
+			return cloudflare.LoadBalancer{}, err
+		}
+
+		created.addLoadBalancer(loadBalancer.ID, loadBalancer.Name)
+
+		return loadBalancer, nil
+	}
+
+	if err != nil {
+		return cloudflare.LoadBalancer{}, err
+	}
+
+	if err := l.lbOps.checkOwnership(service, "load balancer", hostName, loadBalancer.Description); err != nil {
+		return cloudflare.LoadBalancer{}, err
+	}
+
+	if loadBalancerEqual(loadBalancer, desired) {
+		return loadBalancer, nil
+	}
+
+	klog.Info("Updating LB: ", hostName)
+
+	desired.ID = loadBalancer.ID
+
+	updated, err := l.client.UpdateLoadBalancer(ctx, desired)
+	if err != nil {
+		return cloudflare.LoadBalancer{}, err
+	}
+
+	l.deleteUnreferencedLoadBalancerPools(ctx, service, hostName, loadBalancer, updated)
+
+	return updated, nil
+}
+
+// deleteUnreferencedLoadBalancerPools deletes the pools of the hostname that the previous version of the
+// load balancer referenced but the current one doesn't e.g when a partition no longer has any nodes.
+// Failures are only logged as the pools are retried on the next update
+func (l *loadBalancers) deleteUnreferencedLoadBalancerPools(ctx context.Context, service *v1.Service, hostName string, previous cloudflare.LoadBalancer, current cloudflare.LoadBalancer) {
+
+	currentPoolIDs := loadBalancerPoolIDs(current)
+
+	for _, poolId := range loadBalancerPoolIDs(previous) {
+		if slices.Contains(currentPoolIDs, poolId) {
+			continue
+		}
+
+		l.deleteLoadBalancerPoolByID(ctx, service, hostName, poolId)
+	}
+}
+
+// deleteLoadBalancerPoolByID deletes a pool if it belongs to the hostname and is owned by the service, logging any failure
+func (l *loadBalancers) deleteLoadBalancerPoolByID(ctx context.Context, service *v1.Service, hostName string, poolId string) {
+
+	pool, err := l.client.GetPoolConfiguration(ctx, poolId)
+	if err != nil {
+		klog.Warning("Failed to get load balancer pool ", poolId, ": ", err)
+		return
+	}
+
+	if !l.isLoadBalancerPoolName(hostName, pool.Name) {
+		return
+	}
+
+	if err := l.lbOps.checkDeletable(service, "pool", pool.Name, pool.Description); err != nil {
+		klog.Warning(err)
+		return
+	}
+
+	err = l.client.DeleteLoadBalancerPoolByID(ctx, poolId)
+	if err != nil {
+		klog.Warning("Failed to delete load balancer pool ", pool.Name, ": ", err)
+		return
+	}
+
+	klog.Info("Deleted Load Balancer Pool: ", pool.Name)
+}
+
+// buildLoadBalancer returns the load balancer of the hostname described by the service config
+func (l *loadBalancers) buildLoadBalancer(pools []cloudflare.LoadBalancerPool, cfg LoadBalancerConfig, hostName string) (cloudflare.LoadBalancer, error) {
+
+	randomSteering, err := l.buildRandomSteering(cfg, hostName, pools)
+	if err != nil {
+		return cloudflare.LoadBalancer{}, err
+	}
+
+	defaultPools := make([]string, 0, len(pools))
+	for _, pool := range pools {
+		defaultPools = append(defaultPools, pool.ID)
+	}
+
+	return cloudflare.LoadBalancer{
+		Name:                      l.client.FormatResourceName(hostName),
+		FallbackPool:              defaultPools[len(defaultPools)-1],
+		DefaultPools:              defaultPools,
+		RegionPools:               l.buildPoolPartitionMapping(hostName, cfg.PoolPartitionMapping.Regions, pools),
+		CountryPools:              l.buildPoolPartitionMapping(hostName, cfg.PoolPartitionMapping.Countries, pools),
+		PopPools:                  l.buildPoolPartitionMapping(hostName, cfg.PoolPartitionMapping.Pops, pools),
+		TTL:                       cfg.TTL,
+		Proxied:                   cfg.Proxied,
+		SteeringPolicy:            cfg.SteeringPolicy,
+		RandomSteering:            randomSteering,
+		Persistence:               cfg.SessionAffinity,
+		PersistenceTTL:            cfg.SessionAffinityTTL,
+		SessionAffinityAttributes: cfg.SessionAffinityAttributes,
+		AdaptiveRouting:           cfg.AdaptiveRouting,
+		LocationStrategy:          cfg.LocationStrategy,
+	}, nil
+}
+
+// buildRandomSteering maps the pool weights from the service config, which are keyed by
+// pool name, to the pool IDs cloudflare expects. Weights of pools of other hostnames are skipped
+func (l *loadBalancers) buildRandomSteering(cfg LoadBalancerConfig, hostName string, pools []cloudflare.LoadBalancerPool) (*cloudflare.RandomSteering, error) {
+
+	if cfg.RandomSteeringDefaultWeight == 0 && len(cfg.RandomSteeringPoolWeights) == 0 {
+		return nil, nil
+	}
+
+	randomSteering := &cloudflare.RandomSteering{
+		DefaultWeight: cfg.RandomSteeringDefaultWeight,
+	}
+
+	for name, weight := range cfg.RandomSteeringPoolWeights {
+		index := slices.IndexFunc(pools, func(pool cloudflare.LoadBalancerPool) bool {
+			return pool.Name == name
 		})
 
-		return loadBalancer, err
+		otherTarget := slices.ContainsFunc(cfg.Targets, func(target LoadBalancerTarget) bool {
+			return target.HostName != hostName && l.isLoadBalancerPoolName(target.HostName, name)
+		})
+
+		// Pools of the other hostnames of the service are weighted by their own load balancer
+		if index < 0 && otherTarget {
+			continue
+		}
+
+		if index < 0 {
+			return nil, fmt.Errorf("%w: %s references unknown pool %q", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerRandomSteeringPoolWeights, name)
+		}
+
+		if randomSteering.PoolWeights == nil {
+			randomSteering.PoolWeights = map[string]float64{}
+		}
+
+		randomSteering.PoolWeights[pools[index].ID] = weight
 	}
 
-	return loadBalancer, nil
+	return randomSteering, nil
 }
 
-// deleteLoadBalancer will delete a load balancer and its related origin pools and monitors
+// deleteLoadBalancer will delete the load balancers of every hostname of the service and their related origin pools and monitors.
+// Every hostname is attempted even if an earlier one fails, the errors are returned together
 func (l *loadBalancers) deleteLoadBalancer(ctx context.Context, service *v1.Service) error {
 
-	// Delete Load Balancer First
-	hostName, _ := GetLoadBalancerHostName(service) // Ignore err as it has already been checked
-	err := l.client.DeleteLoadBalancer(ctx, hostName)
+	var hostNames []string
+
+	targets, err := GetLoadBalancerTargets(service)
 	if err != nil {
+		// Invalid per port annotations must not keep the service from being deleted, fall back to the hostname annotation
+		hostName, hostNameErr := GetLoadBalancerHostName(service)
+		if hostNameErr != nil {
+			return err
+		}
+
+		klog.Warning("Failed to get hostnames of service ", service.Namespace, "/", service.Name, ", only deleting ", hostName, ": ", err)
+		targets = []LoadBalancerTarget{{HostName: hostName}}
+	}
+
+	for _, target := range targets {
+		hostNames = append(hostNames, target.HostName)
+	}
+
+	var errs []error
+
+	// Load balancers of hostnames the annotations no longer name, e.g removed port hostnames, are found through their marker
+	marked, err := l.markedLoadBalancerHostNames(ctx, service)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, hostName := range marked {
+		if !slices.Contains(hostNames, hostName) {
+			hostNames = append(hostNames, hostName)
+		}
+	}
+
+	for _, hostName := range hostNames {
+		err := l.deleteLoadBalancerTarget(ctx, service, hostName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete load balancer %s: %w", hostName, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deleteLoadBalancerTarget will delete the load balancer of a hostname and its related origin pools and monitor.
+// Resources that are already gone count as deleted, so the deletion can be retried until it succeeds. Resources
+// not owned by the service or still used by other load balancers are left alone so deleting the service isn't blocked by them
+func (l *loadBalancers) deleteLoadBalancerTarget(ctx context.Context, service *v1.Service, hostName string) error {
+
+	// Delete Load Balancer First
+	loadBalancer, err := l.client.GetLoadBalancer(ctx, hostName)
+	if err != nil && !cloudflareClient.IsNotFound(err) {
 		return err
 	}
 
-	klog.Info("Deleted Load Balancer: ", hostName)
+	if err == nil {
+		if err := l.lbOps.checkDeletable(service, "load balancer", hostName, loadBalancer.Description); err != nil {
+			klog.Warning(err)
+			return nil
+		}
+
+		err = l.client.DeleteLoadBalancerByID(ctx, loadBalancer.ID)
+		if err != nil && !cloudflareClient.IsNotFound(err) {
+			// The pools and monitor can't be deleted while the load balancer references them
+			return err
+		}
+
+		klog.Info("Deleted Load Balancer: ", hostName)
+	}
 
-	// Delete Load Balancer pool
-	poolName, err := l.getLoadBalancerPoolName(service)
+	// Pools are looked up by name instead of through the load balancer, so pools left behind
+	// by an earlier attempt, or whose load balancer was deleted by hand, are found too
+	pools, err := l.client.ListLoadBalancerPools(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = l.client.DeleteLoadBalancerPool(ctx, poolName)
+	loadBalancers, err := l.client.ListLoadBalancers(ctx)
 	if err != nil {
 		return err
 	}
 
-	klog.Info("Deleted Load Balancer Pool: ", poolName)
+	var errs []error
+	deletedPools := map[string]bool{}
+
+	// Delete every Load Balancer pool, partitioned services have one per partition
+	for _, pool := range pools {
+		if !l.isLoadBalancerPoolName(hostName, pool.Name) {
+			continue
+		}
+
+		if err := l.lbOps.checkDeletable(service, "pool", pool.Name, pool.Description); err != nil {
+			klog.Warning(err)
+			continue
+		}
+
+		if users := loadBalancersUsingPool(loadBalancers, loadBalancer.ID, pool.ID); len(users) > 0 {
+			klog.Warning("Keeping Load Balancer Pool ", pool.Name, " as it is still used by load balancers ", strings.Join(users, ", "))
+			continue
+		}
+
+		err := l.client.DeleteLoadBalancerPoolByID(ctx, pool.ID)
+		if err != nil && !cloudflareClient.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete pool %s: %w", pool.Name, err))
+			continue
+		}
+
+		deletedPools[pool.ID] = true
+		klog.Info("Deleted Load Balancer Pool: ", pool.Name)
+	}
 
 	// Delete Load Balancer monitor last
-	monitorName, err := l.getLoadBalancerMonitorName(service)
+	err = l.deleteLoadBalancerMonitor(ctx, service, hostName, pools, deletedPools)
 	if err != nil {
-		return err
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// deleteLoadBalancerMonitor deletes the monitor of a hostname unless a pool that wasn't deleted still uses it
+func (l *loadBalancers) deleteLoadBalancerMonitor(ctx context.Context, service *v1.Service, hostName string, pools []cloudflare.LoadBalancerPool, deletedPools map[string]bool) error {
+
+	monitorName := l.getLoadBalancerMonitorName(hostName)
+	monitor, err := l.client.GetLoadBalancerMonitor(ctx, monitorName)
+	if cloudflareClient.IsNotFound(err) {
+		return nil
 	}
 
-	err = l.client.DeleteLoadBalancerMonitor(ctx, monitorName)
 	if err != nil {
 		return err
 	}
 
+	if err := l.lbOps.checkDeletable(service, "monitor", monitorName, monitor.Description); err != nil {
+		klog.Warning(err)
+		return nil
+	}
+
+	for _, pool := range pools {
+		if pool.Monitor == monitor.ID && !deletedPools[pool.ID] {
+			klog.Warning("Keeping Load Balancer Monitor ", monitorName, " as it is still used by pool ", pool.Name)
+			return nil
+		}
+	}
+
+	err = l.client.DeleteLoadBalancerMonitorByID(ctx, monitor.ID)
+	if err != nil && !cloudflareClient.IsNotFound(err) {
+		return fmt.Errorf("failed to delete monitor %s: %w", monitorName, err)
+	}
+
 	klog.Info("Deleted Load Balancer Monitor: ", hostName)
 
 	return nil
 }
+
+// loadBalancersUsingPool returns the names of the load balancers other than the one with ignoreId that reference the pool
+func loadBalancersUsingPool(loadBalancers []cloudflare.LoadBalancer, ignoreId string, poolId string) []string {
+
+	var names []string
+
+	for _, loadBalancer := range loadBalancers {
+		if loadBalancer.ID == ignoreId {
+			continue
+		}
+
+		if slices.Contains(loadBalancerPoolIDs(loadBalancer), poolId) {
+			names = append(names, loadBalancer.Name)
+		}
+	}
+
+	return names
+}
@@ -1,16 +1,36 @@
 package cloudflare
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 
+	cloudflareClient "github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/pkg/cloudflare"
+	"github.com/cloudflare/cloudflare-go"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 const (
 	// serviceAnnotationLoadBalancerID is the name of the loadbalancer
 	serviceAnnotationLoadBalancerHostName = "cloudflare-load-balancer.clyent.dev/hostname"
 
+	// serviceAnnotationLoadBalancerPortHostNames creates a load balancer per named service port in addition to the one of the hostname annotation.
+	// Set to true to use <port-name>.<hostname> for every named port or map port names to hostnames e.g http=www.example.com,grpc=grpc.example.com.
+	// Every port hostname gets its own pools and a monitor probing its port, this is the only way to monitor more than one port of a service
+	serviceAnnotationLoadBalancerPortHostNames = "cloudflare-load-balancer.clyent.dev/port-hostnames"
+
+	// serviceAnnotationLoadBalancerMonitorPort defines the name or number of the service port the load balancer of the hostname annotation
+	// sends traffic to and probes. Defaults to the first port. A pool only has a single monitor, so probing further ports requires port-hostnames
+	serviceAnnotationLoadBalancerMonitorPort = "cloudflare-load-balancer.clyent.dev/monitor-port"
+
 	// serviceAnnotationLoadBalancerMonitorPath is the path the monitor will perform the health check
 	serviceAnnotationLoadBalancerMonitorPath = "cloudflare-load-balancer.clyent.dev/monitor-path"
 
@@ -25,12 +45,244 @@ const (
 
 	// serviceAnnotationLoadBalancerMonitorHeader defines the request header used to pass additional information within HTTP request. Currently supported header is 'Host'.
 	serviceAnnotationLoadBalancerMonitorHeader = "cloudflare-load-balancer.clyent.dev/monitor-header"
+
+	// serviceAnnotationLoadBalancerMonitorHeaders defines additional request headers of the health check either as a JSON object
+	// e.g {"X-Health-Check": "1", "Accept": ["text/plain"]} or as a list e.g X-Health-Check: 1; User-Agent: cloudflare
+	serviceAnnotationLoadBalancerMonitorHeaders = "cloudflare-load-balancer.clyent.dev/monitor-headers"
+
+	// serviceAnnotationLoadBalancerMonitorHeadersSecret defines a secret in the namespace of the service whose keys listed in
+	// monitor-headers-secret-keys are sent as request headers of the health check, keeping tokens out of the annotations.
+	// The secret has to opt in with the monitor-headers label
+	serviceAnnotationLoadBalancerMonitorHeadersSecret = "cloudflare-load-balancer.clyent.dev/monitor-headers-secret"
+
+	// serviceAnnotationLoadBalancerMonitorHeadersSecretKeys defines a comma separated list of keys of the monitor-headers-secret
+	// sent as headers named after the key e.g X-Health-Check-Token
+	serviceAnnotationLoadBalancerMonitorHeadersSecretKeys = "cloudflare-load-balancer.clyent.dev/monitor-headers-secret-keys"
+
+	// serviceAnnotationLoadBalancerMonitorMethod defines the method of the health check e.g GET, HEAD. TCP monitors use connection_established
+	serviceAnnotationLoadBalancerMonitorMethod = "cloudflare-load-balancer.clyent.dev/monitor-method"
+
+	// serviceAnnotationLoadBalancerMonitorExpectedCodes defines the expected HTTP response code or code range e.g 200, 2xx
+	serviceAnnotationLoadBalancerMonitorExpectedCodes = "cloudflare-load-balancer.clyent.dev/monitor-expected-codes"
+
+	// serviceAnnotationLoadBalancerMonitorExpectedBody defines a case-insensitive sub-string to look for in the response body
+	serviceAnnotationLoadBalancerMonitorExpectedBody = "cloudflare-load-balancer.clyent.dev/monitor-expected-body"
+
+	// serviceAnnotationLoadBalancerMonitorInterval defines the interval between health checks in seconds
+	serviceAnnotationLoadBalancerMonitorInterval = "cloudflare-load-balancer.clyent.dev/monitor-interval"
+
+	// serviceAnnotationLoadBalancerMonitorTimeout defines the timeout of a health check in seconds
+	serviceAnnotationLoadBalancerMonitorTimeout = "cloudflare-load-balancer.clyent.dev/monitor-timeout"
+
+	// serviceAnnotationLoadBalancerMonitorRetries defines how many times a timed out health check is retried
+	serviceAnnotationLoadBalancerMonitorRetries = "cloudflare-load-balancer.clyent.dev/monitor-retries"
+
+	// serviceAnnotationLoadBalancerMonitorConsecutiveUp defines how many consecutive successful checks mark an origin healthy
+	serviceAnnotationLoadBalancerMonitorConsecutiveUp = "cloudflare-load-balancer.clyent.dev/monitor-consecutive-up"
+
+	// serviceAnnotationLoadBalancerMonitorConsecutiveDown defines how many consecutive failed checks mark an origin unhealthy
+	serviceAnnotationLoadBalancerMonitorConsecutiveDown = "cloudflare-load-balancer.clyent.dev/monitor-consecutive-down"
+
+	// serviceAnnotationLoadBalancerMonitorFollowRedirects defines whether the health check follows redirects
+	serviceAnnotationLoadBalancerMonitorFollowRedirects = "cloudflare-load-balancer.clyent.dev/monitor-follow-redirects"
+
+	// serviceAnnotationLoadBalancerMonitorMode defines what the monitor probes, either service-port or health-check-node-port for kube-proxy's
+	// health check. Defaults to health-check-node-port for services with externalTrafficPolicy Local
+	serviceAnnotationLoadBalancerMonitorMode = "cloudflare-load-balancer.clyent.dev/monitor-mode"
+
+	// serviceAnnotationLoadBalancerSteeringPolicy defines how the load balancer selects pools e.g off, random, geo, dynamic_latency
+	serviceAnnotationLoadBalancerSteeringPolicy = "cloudflare-load-balancer.clyent.dev/steering-policy"
+
+	// serviceAnnotationLoadBalancerRandomSteeringDefaultWeight defines the weight of pools without an explicit weight when using random steering
+	serviceAnnotationLoadBalancerRandomSteeringDefaultWeight = "cloudflare-load-balancer.clyent.dev/random-steering-default-weight"
+
+	// serviceAnnotationLoadBalancerRandomSteeringPoolWeights defines the weight per pool name when using random steering e.g pool-a=0.8,pool-b=0.2
+	serviceAnnotationLoadBalancerRandomSteeringPoolWeights = "cloudflare-load-balancer.clyent.dev/random-steering-pool-weights"
+
+	// serviceAnnotationLoadBalancerSessionAffinity defines the session affinity type e.g none, cookie, ip_cookie, header. Defaults to ip_cookie when the service uses ClientIP session affinity
+	serviceAnnotationLoadBalancerSessionAffinity = "cloudflare-load-balancer.clyent.dev/session-affinity"
+
+	// serviceAnnotationLoadBalancerSessionAffinityTTL defines how long in seconds a session sticks to an origin
+	serviceAnnotationLoadBalancerSessionAffinityTTL = "cloudflare-load-balancer.clyent.dev/session-affinity-ttl"
+
+	// serviceAnnotationLoadBalancerSessionAffinitySameSite defines the SameSite attribute of the session cookie e.g Auto, Lax, None, Strict
+	serviceAnnotationLoadBalancerSessionAffinitySameSite = "cloudflare-load-balancer.clyent.dev/session-affinity-samesite"
+
+	// serviceAnnotationLoadBalancerSessionAffinitySecure defines the Secure attribute of the session cookie e.g Auto, Always, Never
+	serviceAnnotationLoadBalancerSessionAffinitySecure = "cloudflare-load-balancer.clyent.dev/session-affinity-secure"
+
+	// serviceAnnotationLoadBalancerSessionAffinityDrainDuration defines how long in seconds existing sessions keep using an origin after it is disabled
+	serviceAnnotationLoadBalancerSessionAffinityDrainDuration = "cloudflare-load-balancer.clyent.dev/session-affinity-drain-duration"
+
+	// serviceAnnotationLoadBalancerSessionAffinityZeroDowntimeFailover defines how sessions fail over to another origin e.g none, temporary, sticky
+	serviceAnnotationLoadBalancerSessionAffinityZeroDowntimeFailover = "cloudflare-load-balancer.clyent.dev/session-affinity-zero-downtime-failover"
+
+	// serviceAnnotationLoadBalancerSessionAffinityHeaders defines a comma separated list of request headers used for header session affinity
+	serviceAnnotationLoadBalancerSessionAffinityHeaders = "cloudflare-load-balancer.clyent.dev/session-affinity-headers"
+
+	// serviceAnnotationLoadBalancerSessionAffinityRequireAllHeaders requires all session affinity headers to be present for a session to be created
+	serviceAnnotationLoadBalancerSessionAffinityRequireAllHeaders = "cloudflare-load-balancer.clyent.dev/session-affinity-require-all-headers"
+
+	// serviceAnnotationLoadBalancerProxied defines whether traffic is proxied through cloudflare or the load balancer is DNS only
+	serviceAnnotationLoadBalancerProxied = "cloudflare-load-balancer.clyent.dev/proxied"
+
+	// serviceAnnotationLoadBalancerTTL defines the TTL in seconds of the DNS records, only valid when the load balancer is not proxied
+	serviceAnnotationLoadBalancerTTL = "cloudflare-load-balancer.clyent.dev/ttl"
+
+	// serviceAnnotationLoadBalancerAdaptiveRoutingFailoverAcrossPools extends zero-downtime failover to origins of other pools
+	// when no healthy origin is left in the same pool
+	serviceAnnotationLoadBalancerAdaptiveRoutingFailoverAcrossPools = "cloudflare-load-balancer.clyent.dev/adaptive-routing-failover-across-pools"
+
+	// serviceAnnotationLoadBalancerLocationStrategyMode defines the location used for steering non proxied requests e.g pop, resolver_ip
+	serviceAnnotationLoadBalancerLocationStrategyMode = "cloudflare-load-balancer.clyent.dev/location-strategy-mode"
+
+	// serviceAnnotationLoadBalancerLocationStrategyPreferECS defines when the EDNS client subnet is preferred as location e.g always, never, proximity, geo
+	serviceAnnotationLoadBalancerLocationStrategyPreferECS = "cloudflare-load-balancer.clyent.dev/location-strategy-prefer-ecs"
+
+	// serviceAnnotationLoadBalancerOriginAddressSources defines a comma separated list of node address sources tried in order
+	// to find the address of an origin e.g Annotation,ExternalIP,InternalIP. Hostname and the origin-address node annotation are also supported
+	serviceAnnotationLoadBalancerOriginAddressSources = "cloudflare-load-balancer.clyent.dev/origin-address-sources"
+
+	// serviceAnnotationLoadBalancerOriginIPFamilies defines the IP families of origins, overriding the IP families of the service.
+	// IPv4 or IPv6 only register that family, PreferIPv4 or PreferIPv6 fall back to the other family and DualStack registers both as separate origins
+	serviceAnnotationLoadBalancerOriginIPFamilies = "cloudflare-load-balancer.clyent.dev/origin-ip-families"
+
+	// serviceAnnotationLoadBalancerOriginNodeSelector defines a label selector choosing the nodes registered as origins e.g node-role.example.com/edge=true,!gpu
+	serviceAnnotationLoadBalancerOriginNodeSelector = "cloudflare-load-balancer.clyent.dev/origin-node-selector"
+
+	// serviceAnnotationLoadBalancerOriginIncludeControlPlane registers control plane nodes as origins, they are excluded by default
+	serviceAnnotationLoadBalancerOriginIncludeControlPlane = "cloudflare-load-balancer.clyent.dev/origin-include-control-plane"
+
+	// serviceAnnotationLoadBalancerOriginWeightSource defines where origin weights come from e.g annotation, cpu, none.
+	// annotation reads the relative weight from the origin-weight node annotation, nodes without it weigh 1. cpu uses the allocatable CPU of the node
+	serviceAnnotationLoadBalancerOriginWeightSource = "cloudflare-load-balancer.clyent.dev/origin-weight-source"
+
+	// serviceAnnotationLoadBalancerPoolOriginSteering defines how the pool selects origins e.g random, hash, least_outstanding_requests, least_connections
+	serviceAnnotationLoadBalancerPoolOriginSteering = "cloudflare-load-balancer.clyent.dev/pool-origin-steering"
+
+	// serviceAnnotationLoadBalancerPoolMinimumOrigins defines the minimum number of healthy origins for the pool to be healthy
+	serviceAnnotationLoadBalancerPoolMinimumOrigins = "cloudflare-load-balancer.clyent.dev/pool-minimum-origins"
+
+	// serviceAnnotationLoadBalancerPoolLoadSheddingDefaultPercent defines the percentage of new traffic shed from the pool
+	serviceAnnotationLoadBalancerPoolLoadSheddingDefaultPercent = "cloudflare-load-balancer.clyent.dev/pool-load-shedding-default-percent"
+
+	// serviceAnnotationLoadBalancerPoolLoadSheddingDefaultPolicy defines how new traffic is shed e.g random, hash
+	serviceAnnotationLoadBalancerPoolLoadSheddingDefaultPolicy = "cloudflare-load-balancer.clyent.dev/pool-load-shedding-default-policy"
+
+	// serviceAnnotationLoadBalancerPoolLoadSheddingSessionPercent defines the percentage of existing sessions shed from the pool
+	serviceAnnotationLoadBalancerPoolLoadSheddingSessionPercent = "cloudflare-load-balancer.clyent.dev/pool-load-shedding-session-percent"
+
+	// serviceAnnotationLoadBalancerPoolLoadSheddingSessionPolicy defines how existing sessions are shed, currently only hash
+	serviceAnnotationLoadBalancerPoolLoadSheddingSessionPolicy = "cloudflare-load-balancer.clyent.dev/pool-load-shedding-session-policy"
+
+	// serviceAnnotationLoadBalancerPoolCheckRegions defines a comma separated list of regions health checks run from e.g WNAM,WEU
+	serviceAnnotationLoadBalancerPoolCheckRegions = "cloudflare-load-balancer.clyent.dev/pool-check-regions"
+
+	// serviceAnnotationLoadBalancerPoolNotificationEmail defines a comma separated list of emails notified on health changes of the pool,
+	// an empty value disables the cluster default
+	serviceAnnotationLoadBalancerPoolNotificationEmail = "cloudflare-load-balancer.clyent.dev/pool-notification-email"
+
+	// serviceAnnotationLoadBalancerPoolNotificationFilter defines a comma separated list of health changes to notify about e.g pool:unhealthy,origin.
+	// Entries are origin or pool, optionally followed by :healthy or :unhealthy, anything not listed is not notified
+	serviceAnnotationLoadBalancerPoolNotificationFilter = "cloudflare-load-balancer.clyent.dev/pool-notification-filter"
+
+	// serviceAnnotationLoadBalancerPoolPartition enables one pool per value of the partition node label instead of a single pool for all nodes
+	serviceAnnotationLoadBalancerPoolPartition = "cloudflare-load-balancer.clyent.dev/pool-partition"
+
+	// serviceAnnotationLoadBalancerPoolPartitionLabel defines the node label used to partition nodes into pools. Defaults to topology.kubernetes.io/region
+	serviceAnnotationLoadBalancerPoolPartitionLabel = "cloudflare-load-balancer.clyent.dev/pool-partition-label"
+
+	// serviceAnnotationLoadBalancerPoolPartitionPriority defines a comma separated list of partition values ordering the default pools e.g eu-west,eu-central
+	serviceAnnotationLoadBalancerPoolPartitionPriority = "cloudflare-load-balancer.clyent.dev/pool-partition-priority"
+
+	// serviceAnnotationLoadBalancerPoolPartitionMapping maps cloudflare regions, countries and pops to partition values for geo steering e.g region:WEU=eu-west,eu-central;country:US=us-east;pop:LAX=us-west
+	serviceAnnotationLoadBalancerPoolPartitionMapping = "cloudflare-load-balancer.clyent.dev/pool-partition-mapping"
+)
+
+const (
+	// nodeAnnotationOriginAddress overrides the address of a node used as origin e.g a public IP behind NAT the kubelet doesn't know about
+	nodeAnnotationOriginAddress = "cloudflare-load-balancer.clyent.dev/origin-address"
+
+	// nodeAnnotationOriginWeight defines the weight of a node relative to the other nodes e.g 8 and 64 by the number of cores
+	nodeAnnotationOriginWeight = "cloudflare-load-balancer.clyent.dev/origin-weight"
+)
+
+const (
+	// secretLabelMonitorHeaders has to be set to true on a secret before services may send its keys as health check headers,
+	// so services can't read arbitrary secrets of their namespace through the cloud controller manager
+	secretLabelMonitorHeaders = "cloudflare-load-balancer.clyent.dev/monitor-headers"
+)
+
+const (
+	// originAddressSourceAnnotation reads the origin address from the origin-address node annotation
+	originAddressSourceAnnotation = "Annotation"
+
+	// originWeightSourceAnnotation, originWeightSourceCPU and originWeightSourceNone define where origin weights come from
+	originWeightSourceAnnotation = "annotation"
+	originWeightSourceCPU        = "cpu"
+	originWeightSourceNone       = "none"
+)
+
+const (
+	// loadBalancerDefaultTTL is the TTL of load balancers without a ttl annotation, cloudflare ignores it for proxied ones
+	loadBalancerDefaultTTL = 30
+
+	// monitorModeServicePort probes the service port of each load balancer using the monitor annotations
+	monitorModeServicePort = "service-port"
+
+	// monitorModeHealthCheckNodePort probes the health check node port served by kube-proxy
+	monitorModeHealthCheckNodePort = "health-check-node-port"
+
+	// kubeProxyHealthCheckPath is the path kube-proxy serves the health check node port on
+	kubeProxyHealthCheckPath = "/healthz"
 )
 
 var (
 	errLoadBalancerInvalidAnnotation = errors.New("load balancer invalid loadbalancer annotation")
+
+	loadBalancerMonitorTypes                = []string{"http", "https", "tcp"}
+	loadBalancerMonitorMethods              = []string{"GET", "HEAD", "connection_established"}
+	loadBalancerMonitorExpectedCodesPattern = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+	loadBalancerSteeringPolicies = []string{"off", "random", "geo", "dynamic_latency", "proximity", "least_outstanding_requests", "least_connections"}
+
+	loadBalancerLocationStrategyModes      = []string{"pop", "resolver_ip"}
+	loadBalancerLocationStrategyPreferECSs = []string{"always", "never", "proximity", "geo"}
+
+	loadBalancerOriginAddressSources        = []string{string(v1.NodeExternalIP), string(v1.NodeInternalIP), string(v1.NodeHostName), originAddressSourceAnnotation}
+	loadBalancerDefaultOriginAddressSources = []string{string(v1.NodeExternalIP)}
+
+	loadBalancerOriginIPFamilyModes = []string{"IPv4", "IPv6", "PreferIPv4", "PreferIPv6", "DualStack"}
+
+	loadBalancerPoolOriginSteeringPolicies      = []string{"random", "hash", "least_outstanding_requests", "least_connections"}
+	loadBalancerPoolLoadSheddingDefaultPolicies = []string{"random", "hash"}
+	loadBalancerPoolLoadSheddingSessionPolicies = []string{"hash"}
+	loadBalancerPoolCheckRegionCodes            = []string{"WNAM", "ENAM", "WEU", "EEU", "NSAM", "SSAM", "OC", "ME", "NAF", "SAF", "SAS", "SEAS", "NEAS", "ALL_REGIONS"}
+
+	loadBalancerPoolNotificationTargets = []string{"origin", "pool"}
+	loadBalancerPoolNotificationStates  = []string{"healthy", "unhealthy"}
+
+	loadBalancerSessionAffinities                    = []string{"none", "cookie", "ip_cookie", "header"}
+	loadBalancerSessionAffinitySameSites             = []string{"Auto", "Lax", "None", "Strict"}
+	loadBalancerSessionAffinitySecures               = []string{"Auto", "Always", "Never"}
+	loadBalancerSessionAffinityZeroDowntimeFailovers = []string{"none", "temporary", "sticky"}
+
+	// loadBalancerSessionAffinityTTLRanges defines the session affinity TTL in seconds cloudflare accepts per session affinity type
+	loadBalancerSessionAffinityTTLRanges = map[string][2]int{
+		"cookie":    {1800, 604800},
+		"ip_cookie": {1800, 604800},
+		"header":    {30, 3600},
+	}
 )
 
+// PoolPartitionMapping maps cloudflare region, country and pop codes to the partition values whose
+// pools serve them, in order of preference
+type PoolPartitionMapping struct {
+	Regions   map[string][]string
+	Countries map[string][]string
+	Pops      map[string][]string
+}
+
 func GetLoadBalancerHostName(service *v1.Service) (string, error) {
 	loadBalancerHostName, ok := service.Annotations[serviceAnnotationLoadBalancerHostName]
 	if !ok {
@@ -40,6 +292,105 @@ func GetLoadBalancerHostName(service *v1.Service) (string, error) {
 	return loadBalancerHostName, nil
 }
 
+// GetLoadBalancerMonitorPort returns the service port probed by the monitor of the hostname annotation,
+// nil if the service has no ports
+func GetLoadBalancerMonitorPort(service *v1.Service) (*v1.ServicePort, error) {
+	if len(service.Spec.Ports) == 0 {
+		return nil, nil
+	}
+
+	loadBalancerMonitorPort, ok := service.Annotations[serviceAnnotationLoadBalancerMonitorPort]
+	if !ok {
+		port := service.Spec.Ports[0]
+		return &port, nil
+	}
+
+	for _, port := range service.Spec.Ports {
+		if port.Name == loadBalancerMonitorPort || strconv.Itoa(int(port.Port)) == loadBalancerMonitorPort {
+			return &port, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s references unknown port %q", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorPort, loadBalancerMonitorPort)
+}
+
+// GetLoadBalancerTargets returns the hostnames to create load balancers for, the first one being the hostname annotation
+// followed by the per port hostnames in the order of the service ports
+func GetLoadBalancerTargets(service *v1.Service) ([]LoadBalancerTarget, error) {
+	hostName, err := GetLoadBalancerHostName(service)
+	if err != nil {
+		return nil, err
+	}
+
+	monitorPort, err := GetLoadBalancerMonitorPort(service)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []LoadBalancerTarget{{HostName: hostName, Port: monitorPort}}
+
+	loadBalancerPortHostNames, ok := service.Annotations[serviceAnnotationLoadBalancerPortHostNames]
+	if !ok {
+		return targets, nil
+	}
+
+	hostNames := map[string]string{}
+
+	if enabled, err := strconv.ParseBool(loadBalancerPortHostNames); err == nil {
+		if !enabled {
+			return targets, nil
+		}
+
+		for _, port := range service.Spec.Ports {
+			if port.Name != "" {
+				hostNames[port.Name] = port.Name + "." + hostName
+			}
+		}
+	} else {
+		for _, entry := range splitList(loadBalancerPortHostNames, ",") {
+			portName, portHostName, ok := strings.Cut(entry, "=")
+			portName, portHostName = strings.TrimSpace(portName), strings.TrimSpace(portHostName)
+
+			if !ok || portName == "" || portHostName == "" {
+				return nil, fmt.Errorf("%w: %s entries must be formatted as port-name=hostname, got %q", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerPortHostNames, entry)
+			}
+
+			hostNames[portName] = portHostName
+		}
+	}
+
+	seen := map[string]bool{hostName: true}
+
+	for _, port := range service.Spec.Ports {
+		portHostName, ok := hostNames[port.Name]
+		if !ok {
+			continue
+		}
+
+		delete(hostNames, port.Name)
+
+		if seen[portHostName] {
+			return nil, fmt.Errorf("%w: %s uses hostname %s more than once", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerPortHostNames, portHostName)
+		}
+
+		seen[portHostName] = true
+		targets = append(targets, LoadBalancerTarget{HostName: portHostName, Port: &port})
+	}
+
+	if len(hostNames) > 0 {
+		unknown := make([]string, 0, len(hostNames))
+		for portName := range hostNames {
+			unknown = append(unknown, portName)
+		}
+
+		slices.Sort(unknown)
+
+		return nil, fmt.Errorf("%w: %s references unknown ports %s", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerPortHostNames, strings.Join(unknown, ", "))
+	}
+
+	return targets, nil
+}
+
 func GetLoadBalancerMonitorPath(service *v1.Service) (string, error) {
 	loadBalancerMonitorPath, ok := service.Annotations[serviceAnnotationLoadBalancerMonitorPath]
 	if !ok {
@@ -57,7 +408,7 @@ func GetLoadBalancerMonitorAllowInsecure(service *v1.Service) (bool, error) {
 
 	value, err := strconv.ParseBool(loadBalancerMonitorAllowInsecure)
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorAllowInsecure, err)
 	}
 
 	return value, nil
@@ -69,13 +420,17 @@ func GetLoadBalancerMonitorType(service *v1.Service) (string, error) {
 		return "http", nil
 	}
 
+	if err := validateOneOf(serviceAnnotationLoadBalancerMonitorType, loadBalancerMonitorType, loadBalancerMonitorTypes); err != nil {
+		return "", err
+	}
+
 	return loadBalancerMonitorType, nil
 }
 
 func GetLoadBalancerMonitorProbeZone(service *v1.Service) (string, error) {
 	loadBalancerMonitorProbeZone, ok := service.Annotations[serviceAnnotationLoadBalancerMonitorProbeZone]
 	if !ok {
-		return "http", nil
+		return "", nil
 	}
 
 	return loadBalancerMonitorProbeZone, nil
@@ -89,3 +444,863 @@ func GetLoadBalancerMonitorHeader(service *v1.Service) ([]string, error) {
 
 	return []string{loadBalancerMonitorHeader}, nil
 }
+
+// GetLoadBalancerMonitorHeaders returns the request headers of the health check, combining the
+// monitor-headers annotation with the Host header of the monitor-header annotation
+func GetLoadBalancerMonitorHeaders(service *v1.Service) (map[string][]string, error) {
+	headers := map[string][]string{}
+
+	if loadBalancerMonitorHeaders, ok := service.Annotations[serviceAnnotationLoadBalancerMonitorHeaders]; ok {
+		var err error
+		if strings.HasPrefix(strings.TrimSpace(loadBalancerMonitorHeaders), "{") {
+			headers, err = parseJSONHeaders(loadBalancerMonitorHeaders)
+		} else {
+			headers, err = parseListHeaders(loadBalancerMonitorHeaders)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorHeaders, err)
+		}
+	}
+
+	host, _ := GetLoadBalancerMonitorHeader(service)
+	if host != nil {
+		if _, ok := headers["Host"]; ok {
+			return nil, fmt.Errorf("%w: Host header is set by both %s and %s", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorHeader, serviceAnnotationLoadBalancerMonitorHeaders)
+		}
+
+		headers["Host"] = host
+	}
+
+	return headers, nil
+}
+
+// GetLoadBalancerMonitorHeadersSecret returns the name of the secret holding health check headers and the keys
+// of it to send. Both annotations have to be set together
+func GetLoadBalancerMonitorHeadersSecret(service *v1.Service) (string, []string, error) {
+	secretName := service.Annotations[serviceAnnotationLoadBalancerMonitorHeadersSecret]
+	keys := splitList(service.Annotations[serviceAnnotationLoadBalancerMonitorHeadersSecretKeys], ",")
+
+	switch {
+	case secretName != "" && len(keys) == 0:
+		return "", nil, fmt.Errorf("%w: %s is required with %s", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorHeadersSecretKeys, serviceAnnotationLoadBalancerMonitorHeadersSecret)
+	case secretName == "" && len(keys) > 0:
+		return "", nil, fmt.Errorf("%w: %s is required with %s", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorHeadersSecret, serviceAnnotationLoadBalancerMonitorHeadersSecretKeys)
+	}
+
+	return secretName, keys, nil
+}
+
+// parseJSONHeaders parses a JSON object whose values are either a string or a list of strings
+func parseJSONHeaders(value string) (map[string][]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, err
+	}
+
+	headers := map[string][]string{}
+
+	for name, rawValue := range raw {
+		var single string
+		if err := json.Unmarshal(rawValue, &single); err == nil {
+			headers[http.CanonicalHeaderKey(name)] = []string{single}
+			continue
+		}
+
+		var multiple []string
+		if err := json.Unmarshal(rawValue, &multiple); err != nil {
+			return nil, fmt.Errorf("header %s must be a string or a list of strings", name)
+		}
+
+		headers[http.CanonicalHeaderKey(name)] = multiple
+	}
+
+	return headers, nil
+}
+
+// parseListHeaders parses headers formatted as Key: value; Key2: value. A repeated key adds another value
+func parseListHeaders(value string) (map[string][]string, error) {
+	headers := map[string][]string{}
+
+	for _, entry := range splitList(value, ";") {
+		name, headerValue, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+
+		if !ok || name == "" {
+			return nil, fmt.Errorf("entries must be formatted as <name>: <value>, got %q", entry)
+		}
+
+		name = http.CanonicalHeaderKey(name)
+		headers[name] = append(headers[name], strings.TrimSpace(headerValue))
+	}
+
+	return headers, nil
+}
+
+// GetLoadBalancerMonitorMethod returns the health check method, defaulting to GET or connection_established for TCP monitors
+func GetLoadBalancerMonitorMethod(service *v1.Service) (string, error) {
+	loadBalancerMonitorMethod, ok := service.Annotations[serviceAnnotationLoadBalancerMonitorMethod]
+	if !ok {
+		monitorType, _ := GetLoadBalancerMonitorType(service)
+		if monitorType == "tcp" {
+			return "connection_established", nil
+		}
+
+		return "GET", nil
+	}
+
+	if err := validateOneOf(serviceAnnotationLoadBalancerMonitorMethod, loadBalancerMonitorMethod, loadBalancerMonitorMethods); err != nil {
+		return "", err
+	}
+
+	return loadBalancerMonitorMethod, nil
+}
+
+func GetLoadBalancerMonitorExpectedCodes(service *v1.Service) (string, error) {
+	loadBalancerMonitorExpectedCodes, ok := service.Annotations[serviceAnnotationLoadBalancerMonitorExpectedCodes]
+	if !ok {
+		return "2xx", nil
+	}
+
+	if !loadBalancerMonitorExpectedCodesPattern.MatchString(loadBalancerMonitorExpectedCodes) {
+		return "", fmt.Errorf("%w: %s must be a status code or range such as 200 or 2xx, got %q", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorExpectedCodes, loadBalancerMonitorExpectedCodes)
+	}
+
+	return loadBalancerMonitorExpectedCodes, nil
+}
+
+func GetLoadBalancerMonitorExpectedBody(service *v1.Service) (string, error) {
+	return service.Annotations[serviceAnnotationLoadBalancerMonitorExpectedBody], nil
+}
+
+func GetLoadBalancerMonitorInterval(service *v1.Service) (int, error) {
+	return getIntAnnotation(service, serviceAnnotationLoadBalancerMonitorInterval, 60, 5, 3600)
+}
+
+func GetLoadBalancerMonitorTimeout(service *v1.Service) (int, error) {
+	return getIntAnnotation(service, serviceAnnotationLoadBalancerMonitorTimeout, 5, 1, 10)
+}
+
+func GetLoadBalancerMonitorRetries(service *v1.Service) (int, error) {
+	return getIntAnnotation(service, serviceAnnotationLoadBalancerMonitorRetries, 2, 0, 5)
+}
+
+func GetLoadBalancerMonitorConsecutiveUp(service *v1.Service) (int, error) {
+	return getIntAnnotation(service, serviceAnnotationLoadBalancerMonitorConsecutiveUp, 0, 0, 100)
+}
+
+func GetLoadBalancerMonitorConsecutiveDown(service *v1.Service) (int, error) {
+	return getIntAnnotation(service, serviceAnnotationLoadBalancerMonitorConsecutiveDown, 0, 0, 100)
+}
+
+func GetLoadBalancerMonitorFollowRedirects(service *v1.Service) (bool, error) {
+	loadBalancerMonitorFollowRedirects, ok := service.Annotations[serviceAnnotationLoadBalancerMonitorFollowRedirects]
+	if !ok {
+		return true, nil
+	}
+
+	value, err := strconv.ParseBool(loadBalancerMonitorFollowRedirects)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorFollowRedirects, err)
+	}
+
+	return value, nil
+}
+
+func GetLoadBalancerMonitorMode(service *v1.Service) (string, error) {
+	loadBalancerMonitorMode, ok := service.Annotations[serviceAnnotationLoadBalancerMonitorMode]
+	if !ok {
+		if service.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyLocal && service.Spec.HealthCheckNodePort != 0 {
+			return monitorModeHealthCheckNodePort, nil
+		}
+
+		return monitorModeServicePort, nil
+	}
+
+	if err := validateOneOf(serviceAnnotationLoadBalancerMonitorMode, loadBalancerMonitorMode, []string{monitorModeServicePort, monitorModeHealthCheckNodePort}); err != nil {
+		return "", err
+	}
+
+	return loadBalancerMonitorMode, nil
+}
+
+func GetLoadBalancerSteeringPolicy(service *v1.Service) (string, error) {
+	loadBalancerSteeringPolicy, ok := service.Annotations[serviceAnnotationLoadBalancerSteeringPolicy]
+	if !ok {
+		return "", nil
+	}
+
+	if err := validateOneOf(serviceAnnotationLoadBalancerSteeringPolicy, loadBalancerSteeringPolicy, loadBalancerSteeringPolicies); err != nil {
+		return "", err
+	}
+
+	return loadBalancerSteeringPolicy, nil
+}
+
+func GetLoadBalancerRandomSteeringDefaultWeight(service *v1.Service) (float64, error) {
+	loadBalancerRandomSteeringDefaultWeight, ok := service.Annotations[serviceAnnotationLoadBalancerRandomSteeringDefaultWeight]
+	if !ok {
+		return 0, nil
+	}
+
+	return parseWeight(serviceAnnotationLoadBalancerRandomSteeringDefaultWeight, loadBalancerRandomSteeringDefaultWeight)
+}
+
+// GetLoadBalancerRandomSteeringPoolWeights returns the random steering weights keyed by pool name
+func GetLoadBalancerRandomSteeringPoolWeights(service *v1.Service) (map[string]float64, error) {
+	loadBalancerRandomSteeringPoolWeights, ok := service.Annotations[serviceAnnotationLoadBalancerRandomSteeringPoolWeights]
+	if !ok {
+		return nil, nil
+	}
+
+	weights := map[string]float64{}
+
+	for _, entry := range strings.Split(loadBalancerRandomSteeringPoolWeights, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%w: %s entries must be formatted as <pool>=<weight>, got %q", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerRandomSteeringPoolWeights, entry)
+		}
+
+		weight, err := parseWeight(serviceAnnotationLoadBalancerRandomSteeringPoolWeights, strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+
+		weights[strings.TrimSpace(name)] = weight
+	}
+
+	return weights, nil
+}
+
+// parseWeight parses a steering weight which cloudflare only accepts between 0 and 1
+func parseWeight(annotation string, value string) (float64, error) {
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, annotation, err)
+	}
+
+	if weight < 0 || weight > 1 {
+		return 0, fmt.Errorf("%w: %s weights must be between 0 and 1, got %v", errLoadBalancerInvalidAnnotation, annotation, weight)
+	}
+
+	return weight, nil
+}
+
+// GetLoadBalancerSessionAffinity returns the session affinity type and TTL. Without the annotation
+// ClientIP session affinity on the service maps to ip_cookie
+func GetLoadBalancerSessionAffinity(service *v1.Service) (string, int, error) {
+	ttl, err := getSessionAffinityTTL(service)
+	if err != nil {
+		return "", 0, err
+	}
+
+	loadBalancerSessionAffinity, ok := service.Annotations[serviceAnnotationLoadBalancerSessionAffinity]
+	if !ok {
+		if service.Spec.SessionAffinity != v1.ServiceAffinityClientIP {
+			return "", 0, nil
+		}
+
+		if ttl == 0 && service.Spec.SessionAffinityConfig != nil && service.Spec.SessionAffinityConfig.ClientIP != nil && service.Spec.SessionAffinityConfig.ClientIP.TimeoutSeconds != nil {
+			ttl = int(*service.Spec.SessionAffinityConfig.ClientIP.TimeoutSeconds)
+		}
+
+		return "ip_cookie", ttl, nil
+	}
+
+	if err := validateOneOf(serviceAnnotationLoadBalancerSessionAffinity, loadBalancerSessionAffinity, loadBalancerSessionAffinities); err != nil {
+		return "", 0, err
+	}
+
+	if loadBalancerSessionAffinity == "none" {
+		return loadBalancerSessionAffinity, 0, nil
+	}
+
+	return loadBalancerSessionAffinity, ttl, nil
+}
+
+func getSessionAffinityTTL(service *v1.Service) (int, error) {
+	loadBalancerSessionAffinityTTL, ok := service.Annotations[serviceAnnotationLoadBalancerSessionAffinityTTL]
+	if !ok {
+		return 0, nil
+	}
+
+	value, err := strconv.Atoi(loadBalancerSessionAffinityTTL)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerSessionAffinityTTL, err)
+	}
+
+	if value <= 0 {
+		return 0, fmt.Errorf("%w: %s must be positive, got %d", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerSessionAffinityTTL, value)
+	}
+
+	return value, nil
+}
+
+// GetLoadBalancerSessionAffinityAttributes returns the session affinity attributes or nil when none are set
+func GetLoadBalancerSessionAffinityAttributes(service *v1.Service) (*cloudflare.SessionAffinityAttributes, error) {
+	var errs []error
+	var set bool
+	attributes := &cloudflare.SessionAffinityAttributes{}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerSessionAffinitySameSite]; ok {
+		set = true
+		attributes.SameSite = value
+		errs = append(errs, validateOneOf(serviceAnnotationLoadBalancerSessionAffinitySameSite, value, loadBalancerSessionAffinitySameSites))
+	}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerSessionAffinitySecure]; ok {
+		set = true
+		attributes.Secure = value
+		errs = append(errs, validateOneOf(serviceAnnotationLoadBalancerSessionAffinitySecure, value, loadBalancerSessionAffinitySecures))
+	}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerSessionAffinityZeroDowntimeFailover]; ok {
+		set = true
+		attributes.ZeroDowntimeFailover = value
+		errs = append(errs, validateOneOf(serviceAnnotationLoadBalancerSessionAffinityZeroDowntimeFailover, value, loadBalancerSessionAffinityZeroDowntimeFailovers))
+	}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerSessionAffinityDrainDuration]; ok {
+		set = true
+		drainDuration, err := strconv.Atoi(value)
+		if err == nil && drainDuration < 0 {
+			err = fmt.Errorf("must not be negative, got %d", drainDuration)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerSessionAffinityDrainDuration, err))
+		}
+
+		attributes.DrainDuration = drainDuration
+	}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerSessionAffinityHeaders]; ok {
+		set = true
+		attributes.Headers = splitList(value, ",")
+	}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerSessionAffinityRequireAllHeaders]; ok {
+		set = true
+		requireAllHeaders, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerSessionAffinityRequireAllHeaders, err))
+		}
+
+		attributes.RequireAllHeaders = requireAllHeaders
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if !set {
+		return nil, nil
+	}
+
+	return attributes, nil
+}
+
+// validateLoadBalancerSessionAffinity checks the session affinity TTL is within the range cloudflare accepts for the
+// session affinity type and header session affinity has headers to match on
+func validateLoadBalancerSessionAffinity(service *v1.Service, sessionAffinity string, ttl int, attributes *cloudflare.SessionAffinityAttributes) error {
+	var errs []error
+
+	if ttlRange, ok := loadBalancerSessionAffinityTTLRanges[sessionAffinity]; ok && ttl != 0 && (ttl < ttlRange[0] || ttl > ttlRange[1]) {
+		source := serviceAnnotationLoadBalancerSessionAffinityTTL
+		if _, ok := service.Annotations[serviceAnnotationLoadBalancerSessionAffinityTTL]; !ok {
+			source = "spec.sessionAffinityConfig.clientIP.timeoutSeconds"
+		}
+
+		errs = append(errs, fmt.Errorf("%w: %s must be between %d and %d for %s session affinity, got %d", errLoadBalancerInvalidAnnotation, source, ttlRange[0], ttlRange[1], sessionAffinity, ttl))
+	}
+
+	if sessionAffinity == "header" && (attributes == nil || len(attributes.Headers) == 0) {
+		errs = append(errs, fmt.Errorf("%w: %s is required for header session affinity", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerSessionAffinityHeaders))
+	}
+
+	return errors.Join(errs...)
+}
+
+// getIntAnnotation parses an integer annotation and checks it is within [min, max]
+func getIntAnnotation(service *v1.Service, annotation string, defaultValue int, min int, max int) (int, error) {
+	value, ok := service.Annotations[annotation]
+	if !ok {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, annotation, err)
+	}
+
+	if parsed < min || parsed > max {
+		return 0, fmt.Errorf("%w: %s must be between %d and %d, got %d", errLoadBalancerInvalidAnnotation, annotation, min, max, parsed)
+	}
+
+	return parsed, nil
+}
+
+// parsePercent parses a percentage between 0 and 100
+func parsePercent(annotation string, value string) (float32, error) {
+	percent, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, annotation, err)
+	}
+
+	if percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("%w: %s must be between 0 and 100, got %v", errLoadBalancerInvalidAnnotation, annotation, percent)
+	}
+
+	return float32(percent), nil
+}
+
+// validateOneOf checks that an annotation value is one of the allowed values
+func validateOneOf(annotation string, value string, allowed []string) error {
+	if !slices.Contains(allowed, value) {
+		return fmt.Errorf("%w: %s must be one of %s, got %q", errLoadBalancerInvalidAnnotation, annotation, strings.Join(allowed, ", "), value)
+	}
+
+	return nil
+}
+
+func GetLoadBalancerProxied(service *v1.Service) (bool, error) {
+	loadBalancerProxied, ok := service.Annotations[serviceAnnotationLoadBalancerProxied]
+	if !ok {
+		return true, nil
+	}
+
+	value, err := strconv.ParseBool(loadBalancerProxied)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerProxied, err)
+	}
+
+	return value, nil
+}
+
+func GetLoadBalancerTTL(service *v1.Service) (int, error) {
+	return getIntAnnotation(service, serviceAnnotationLoadBalancerTTL, loadBalancerDefaultTTL, 30, 86400)
+}
+
+// GetLoadBalancerAdaptiveRouting returns the adaptive routing settings or nil when none are set
+func GetLoadBalancerAdaptiveRouting(service *v1.Service) (*cloudflare.AdaptiveRouting, error) {
+	loadBalancerFailoverAcrossPools, ok := service.Annotations[serviceAnnotationLoadBalancerAdaptiveRoutingFailoverAcrossPools]
+	if !ok {
+		return nil, nil
+	}
+
+	failoverAcrossPools, err := strconv.ParseBool(loadBalancerFailoverAcrossPools)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerAdaptiveRoutingFailoverAcrossPools, err)
+	}
+
+	return &cloudflare.AdaptiveRouting{FailoverAcrossPools: &failoverAcrossPools}, nil
+}
+
+// GetLoadBalancerLocationStrategy returns the location strategy or nil when none is set
+func GetLoadBalancerLocationStrategy(service *v1.Service) (*cloudflare.LocationStrategy, error) {
+	var errs []error
+	var set bool
+	locationStrategy := &cloudflare.LocationStrategy{}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerLocationStrategyMode]; ok {
+		set = true
+		locationStrategy.Mode = value
+		errs = append(errs, validateOneOf(serviceAnnotationLoadBalancerLocationStrategyMode, value, loadBalancerLocationStrategyModes))
+	}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerLocationStrategyPreferECS]; ok {
+		set = true
+		locationStrategy.PreferECS = value
+		errs = append(errs, validateOneOf(serviceAnnotationLoadBalancerLocationStrategyPreferECS, value, loadBalancerLocationStrategyPreferECSs))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if !set {
+		return nil, nil
+	}
+
+	return locationStrategy, nil
+}
+
+// GetLoadBalancerOriginAddressSources returns the node address sources tried in order, falling back to defaultValue
+func GetLoadBalancerOriginAddressSources(service *v1.Service, defaultValue string) ([]string, error) {
+	loadBalancerOriginAddressSources, ok := service.Annotations[serviceAnnotationLoadBalancerOriginAddressSources]
+	if !ok {
+		loadBalancerOriginAddressSources = defaultValue
+	}
+
+	sources, err := parseOriginAddressSources(loadBalancerOriginAddressSources)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerOriginAddressSources, err)
+	}
+
+	return sources, nil
+}
+
+// parseOriginAddressSources parses a comma separated list of node address sources, defaulting to the external IP
+func parseOriginAddressSources(value string) ([]string, error) {
+	sources := splitList(value, ",")
+	if len(sources) == 0 {
+		return loadBalancerDefaultOriginAddressSources, nil
+	}
+
+	for _, source := range sources {
+		if !slices.Contains(loadBalancerOriginAddressSources, source) {
+			return nil, fmt.Errorf("source must be one of %s, got %q", strings.Join(loadBalancerOriginAddressSources, ", "), source)
+		}
+	}
+
+	return sources, nil
+}
+
+// GetLoadBalancerOriginIPFamilies returns the IP families of origins in order of preference and whether every family
+// gets its own origin. Without annotation the IP families and policy of the service are used, preferring IPv4 if unset
+func GetLoadBalancerOriginIPFamilies(service *v1.Service) ([]v1.IPFamily, bool, error) {
+	loadBalancerOriginIPFamilies, ok := service.Annotations[serviceAnnotationLoadBalancerOriginIPFamilies]
+	if !ok {
+		families := service.Spec.IPFamilies
+		if len(families) == 0 {
+			return []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}, false, nil
+		}
+
+		if service.Spec.IPFamilyPolicy == nil || *service.Spec.IPFamilyPolicy == v1.IPFamilyPolicySingleStack {
+			// kube-proxy only serves single stack services on addresses of their family
+			return families[:1], false, nil
+		}
+
+		return families, true, nil
+	}
+
+	if err := validateOneOf(serviceAnnotationLoadBalancerOriginIPFamilies, loadBalancerOriginIPFamilies, loadBalancerOriginIPFamilyModes); err != nil {
+		return nil, false, err
+	}
+
+	switch loadBalancerOriginIPFamilies {
+	case "IPv4":
+		return []v1.IPFamily{v1.IPv4Protocol}, false, nil
+	case "IPv6":
+		return []v1.IPFamily{v1.IPv6Protocol}, false, nil
+	case "PreferIPv6":
+		return []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol}, false, nil
+	case "DualStack":
+		return []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}, true, nil
+	}
+
+	return []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}, false, nil
+}
+
+// GetLoadBalancerOriginNodeSelector returns the selector of the nodes registered as origins, falling back to defaultValue
+func GetLoadBalancerOriginNodeSelector(service *v1.Service, defaultValue string) (labels.Selector, error) {
+	loadBalancerOriginNodeSelector, ok := service.Annotations[serviceAnnotationLoadBalancerOriginNodeSelector]
+	if !ok {
+		loadBalancerOriginNodeSelector = defaultValue
+	}
+
+	selector, err := labels.Parse(loadBalancerOriginNodeSelector)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerOriginNodeSelector, err)
+	}
+
+	return selector, nil
+}
+
+func GetLoadBalancerOriginIncludeControlPlane(service *v1.Service) (bool, error) {
+	loadBalancerOriginIncludeControlPlane, ok := service.Annotations[serviceAnnotationLoadBalancerOriginIncludeControlPlane]
+	if !ok {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(loadBalancerOriginIncludeControlPlane)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerOriginIncludeControlPlane, err)
+	}
+
+	return value, nil
+}
+
+func GetLoadBalancerOriginWeightSource(service *v1.Service) (string, error) {
+	loadBalancerOriginWeightSource, ok := service.Annotations[serviceAnnotationLoadBalancerOriginWeightSource]
+	if !ok {
+		return originWeightSourceAnnotation, nil
+	}
+
+	if err := validateOneOf(serviceAnnotationLoadBalancerOriginWeightSource, loadBalancerOriginWeightSource, []string{originWeightSourceAnnotation, originWeightSourceCPU, originWeightSourceNone}); err != nil {
+		return "", err
+	}
+
+	return loadBalancerOriginWeightSource, nil
+}
+
+func GetLoadBalancerPoolOriginSteering(service *v1.Service) (string, error) {
+	loadBalancerPoolOriginSteering, ok := service.Annotations[serviceAnnotationLoadBalancerPoolOriginSteering]
+	if !ok {
+		return "", nil
+	}
+
+	if err := validateOneOf(serviceAnnotationLoadBalancerPoolOriginSteering, loadBalancerPoolOriginSteering, loadBalancerPoolOriginSteeringPolicies); err != nil {
+		return "", err
+	}
+
+	return loadBalancerPoolOriginSteering, nil
+}
+
+func GetLoadBalancerPoolMinimumOrigins(service *v1.Service) (*int, error) {
+	if _, ok := service.Annotations[serviceAnnotationLoadBalancerPoolMinimumOrigins]; !ok {
+		return nil, nil
+	}
+
+	value, err := getIntAnnotation(service, serviceAnnotationLoadBalancerPoolMinimumOrigins, 1, 1, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+// GetLoadBalancerPoolLoadShedding returns the load shedding settings of the pool or nil when none are set
+func GetLoadBalancerPoolLoadShedding(service *v1.Service) (*cloudflare.LoadBalancerLoadShedding, error) {
+	var errs []error
+	var set bool
+	loadShedding := &cloudflare.LoadBalancerLoadShedding{}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerPoolLoadSheddingDefaultPercent]; ok {
+		set = true
+		percent, err := parsePercent(serviceAnnotationLoadBalancerPoolLoadSheddingDefaultPercent, value)
+		errs = append(errs, err)
+		loadShedding.DefaultPercent = percent
+	}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerPoolLoadSheddingDefaultPolicy]; ok {
+		set = true
+		loadShedding.DefaultPolicy = value
+		errs = append(errs, validateOneOf(serviceAnnotationLoadBalancerPoolLoadSheddingDefaultPolicy, value, loadBalancerPoolLoadSheddingDefaultPolicies))
+	}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerPoolLoadSheddingSessionPercent]; ok {
+		set = true
+		percent, err := parsePercent(serviceAnnotationLoadBalancerPoolLoadSheddingSessionPercent, value)
+		errs = append(errs, err)
+		loadShedding.SessionPercent = percent
+	}
+
+	if value, ok := service.Annotations[serviceAnnotationLoadBalancerPoolLoadSheddingSessionPolicy]; ok {
+		set = true
+		loadShedding.SessionPolicy = value
+		errs = append(errs, validateOneOf(serviceAnnotationLoadBalancerPoolLoadSheddingSessionPolicy, value, loadBalancerPoolLoadSheddingSessionPolicies))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if !set {
+		return nil, nil
+	}
+
+	return loadShedding, nil
+}
+
+func GetLoadBalancerPoolCheckRegions(service *v1.Service) ([]string, error) {
+	loadBalancerPoolCheckRegions, ok := service.Annotations[serviceAnnotationLoadBalancerPoolCheckRegions]
+	if !ok {
+		return nil, nil
+	}
+
+	regions := splitList(loadBalancerPoolCheckRegions, ",")
+	for _, region := range regions {
+		if err := validateOneOf(serviceAnnotationLoadBalancerPoolCheckRegions, region, loadBalancerPoolCheckRegionCodes); err != nil {
+			return nil, err
+		}
+	}
+
+	return regions, nil
+}
+
+// GetLoadBalancerPoolNotificationEmail returns the emails notified on health changes of the pool, falling back to defaultValue
+func GetLoadBalancerPoolNotificationEmail(service *v1.Service, defaultValue string) (string, error) {
+	loadBalancerPoolNotificationEmail, ok := service.Annotations[serviceAnnotationLoadBalancerPoolNotificationEmail]
+	if !ok {
+		loadBalancerPoolNotificationEmail = defaultValue
+	}
+
+	email, err := parseNotificationEmail(loadBalancerPoolNotificationEmail)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerPoolNotificationEmail, err)
+	}
+
+	return email, nil
+}
+
+// GetLoadBalancerPoolNotificationFilter returns the notification filter of the pool, falling back to defaultValue
+func GetLoadBalancerPoolNotificationFilter(service *v1.Service, defaultValue string) (*cloudflareClient.LoadBalancerPoolNotificationFilter, error) {
+	loadBalancerPoolNotificationFilter, ok := service.Annotations[serviceAnnotationLoadBalancerPoolNotificationFilter]
+	if !ok {
+		loadBalancerPoolNotificationFilter = defaultValue
+	}
+
+	filter, err := parseNotificationFilter(loadBalancerPoolNotificationFilter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerPoolNotificationFilter, err)
+	}
+
+	return filter, nil
+}
+
+// parseNotificationEmail validates a comma separated list of emails and returns it in the format cloudflare expects
+func parseNotificationEmail(value string) (string, error) {
+	emails := splitList(value, ",")
+
+	for _, email := range emails {
+		address, err := mail.ParseAddress(email)
+		if err != nil || address.Address != email {
+			return "", fmt.Errorf("invalid email %q", email)
+		}
+	}
+
+	return strings.Join(emails, ","), nil
+}
+
+// parseNotificationFilter parses a comma separated list of origin or pool entries, optionally followed by :healthy or :unhealthy.
+// An empty value returns no filter so every health change is notified
+func parseNotificationFilter(value string) (*cloudflareClient.LoadBalancerPoolNotificationFilter, error) {
+	entries := splitList(value, ",")
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	states := map[string]map[string]bool{}
+
+	for _, entry := range entries {
+		target, state, hasState := strings.Cut(entry, ":")
+
+		if !slices.Contains(loadBalancerPoolNotificationTargets, target) {
+			return nil, fmt.Errorf("target must be one of %s, got %q", strings.Join(loadBalancerPoolNotificationTargets, ", "), target)
+		}
+
+		if states[target] == nil {
+			states[target] = map[string]bool{}
+		}
+
+		if !hasState {
+			for _, state := range loadBalancerPoolNotificationStates {
+				states[target][state] = true
+			}
+			continue
+		}
+
+		if !slices.Contains(loadBalancerPoolNotificationStates, state) {
+			return nil, fmt.Errorf("state of %s must be one of %s, got %q", target, strings.Join(loadBalancerPoolNotificationStates, ", "), state)
+		}
+
+		states[target][state] = true
+	}
+
+	buildTarget := func(target string) *cloudflareClient.LoadBalancerPoolNotificationFilterTarget {
+		healthy, unhealthy := states[target]["healthy"], states[target]["unhealthy"]
+
+		switch {
+		case healthy && unhealthy:
+			return &cloudflareClient.LoadBalancerPoolNotificationFilterTarget{}
+		case healthy || unhealthy:
+			return &cloudflareClient.LoadBalancerPoolNotificationFilterTarget{Healthy: &healthy}
+		}
+
+		return &cloudflareClient.LoadBalancerPoolNotificationFilterTarget{Disable: true}
+	}
+
+	return &cloudflareClient.LoadBalancerPoolNotificationFilter{
+		Origin: buildTarget("origin"),
+		Pool:   buildTarget("pool"),
+	}, nil
+}
+
+func GetLoadBalancerPoolPartition(service *v1.Service) (bool, error) {
+	loadBalancerPoolPartition, ok := service.Annotations[serviceAnnotationLoadBalancerPoolPartition]
+	if !ok {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(loadBalancerPoolPartition)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerPoolPartition, err)
+	}
+
+	return value, nil
+}
+
+func GetLoadBalancerPoolPartitionLabel(service *v1.Service) (string, error) {
+	loadBalancerPoolPartitionLabel, ok := service.Annotations[serviceAnnotationLoadBalancerPoolPartitionLabel]
+	if !ok {
+		return v1.LabelTopologyRegion, nil
+	}
+
+	return loadBalancerPoolPartitionLabel, nil
+}
+
+func GetLoadBalancerPoolPartitionPriority(service *v1.Service) ([]string, error) {
+	loadBalancerPoolPartitionPriority, ok := service.Annotations[serviceAnnotationLoadBalancerPoolPartitionPriority]
+	if !ok {
+		return nil, nil
+	}
+
+	return splitList(loadBalancerPoolPartitionPriority, ","), nil
+}
+
+func GetLoadBalancerPoolPartitionMapping(service *v1.Service) (PoolPartitionMapping, error) {
+	mapping := PoolPartitionMapping{
+		Regions:   map[string][]string{},
+		Countries: map[string][]string{},
+		Pops:      map[string][]string{},
+	}
+
+	loadBalancerPoolPartitionMapping, ok := service.Annotations[serviceAnnotationLoadBalancerPoolPartitionMapping]
+	if !ok {
+		return mapping, nil
+	}
+
+	for _, entry := range splitList(loadBalancerPoolPartitionMapping, ";") {
+		kind, rest, ok := strings.Cut(entry, ":")
+		code, values, hasValues := strings.Cut(rest, "=")
+		code = strings.TrimSpace(code)
+
+		if !ok || !hasValues || code == "" {
+			return PoolPartitionMapping{}, fmt.Errorf("%w: %s entries must be formatted as <region|country|pop>:<code>=<partition>[,<partition>], got %q", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerPoolPartitionMapping, entry)
+		}
+
+		partitions := splitList(values, ",")
+
+		switch strings.TrimSpace(kind) {
+		case "region":
+			mapping.Regions[code] = append(mapping.Regions[code], partitions...)
+		case "country":
+			mapping.Countries[code] = append(mapping.Countries[code], partitions...)
+		case "pop":
+			mapping.Pops[code] = append(mapping.Pops[code], partitions...)
+		default:
+			return PoolPartitionMapping{}, fmt.Errorf("%w: %s entries must start with region, country or pop, got %q", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerPoolPartitionMapping, entry)
+		}
+	}
+
+	return mapping, nil
+}
+
+// splitList splits value by sep, trimming whitespace and dropping empty entries
+func splitList(value string, sep string) []string {
+	var result []string
+
+	for _, entry := range strings.Split(value, sep) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			result = append(result, entry)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,595 @@
+package cloudflare
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/internal/config"
+	cloudflareClient "github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/pkg/cloudflare"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestService returns a load balancer service with the hostname annotation, the given annotations and ports
+func newTestService(annotations map[string]string, ports ...v1.ServicePort) *v1.Service {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "web",
+			Annotations: map[string]string{serviceAnnotationLoadBalancerHostName: "example.com"},
+		},
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeLoadBalancer,
+			Ports: ports,
+		},
+	}
+
+	for key, value := range annotations {
+		service.Annotations[key] = value
+	}
+
+	return service
+}
+
+var (
+	testPortHTTP = v1.ServicePort{Name: "http", Port: 80}
+	testPortGRPC = v1.ServicePort{Name: "grpc", Port: 9090}
+)
+
+func TestParseLoadBalancerConfigDefaults(t *testing.T) {
+	cfg, err := ParseLoadBalancerConfig(newTestService(nil, testPortHTTP), config.LoadBalancerConfiguration{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []LoadBalancerTarget{{HostName: "example.com", Port: &testPortHTTP}}; !reflect.DeepEqual(cfg.Targets, want) {
+		t.Errorf("Targets = %+v, want %+v", cfg.Targets, want)
+	}
+
+	if !cfg.Proxied || cfg.TTL != loadBalancerDefaultTTL {
+		t.Errorf("Proxied, TTL = %v, %d, want true, %d", cfg.Proxied, cfg.TTL, loadBalancerDefaultTTL)
+	}
+
+	monitor := cfg.Monitor
+	if monitor.Mode != monitorModeServicePort || monitor.Type != "http" || monitor.Method != "GET" || monitor.Path != "/" ||
+		monitor.ExpectedCodes != "2xx" || !monitor.FollowRedirects || monitor.Interval != 60 || monitor.Timeout != 5 || monitor.Retries != 2 {
+		t.Errorf("unexpected monitor defaults %+v", monitor)
+	}
+
+	if want := []string{string(v1.NodeExternalIP)}; !reflect.DeepEqual(cfg.OriginAddressSources, want) {
+		t.Errorf("OriginAddressSources = %v, want %v", cfg.OriginAddressSources, want)
+	}
+
+	if want := []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}; !reflect.DeepEqual(cfg.OriginIPFamilies, want) || cfg.OriginDualStack {
+		t.Errorf("OriginIPFamilies, OriginDualStack = %v, %v, want %v, false", cfg.OriginIPFamilies, cfg.OriginDualStack, want)
+	}
+
+	if !cfg.OriginNodeSelector.Empty() {
+		t.Errorf("OriginNodeSelector = %v, want empty", cfg.OriginNodeSelector)
+	}
+
+	if cfg.OriginWeightSource != originWeightSourceAnnotation {
+		t.Errorf("OriginWeightSource = %q, want %q", cfg.OriginWeightSource, originWeightSourceAnnotation)
+	}
+
+	if cfg.PoolNotificationFilter != nil || cfg.PoolNotificationEmail != "" {
+		t.Errorf("PoolNotificationFilter, PoolNotificationEmail = %+v, %q, want none", cfg.PoolNotificationFilter, cfg.PoolNotificationEmail)
+	}
+
+	if cfg.PoolPartition || cfg.PoolPartitionLabel != v1.LabelTopologyRegion {
+		t.Errorf("PoolPartition, PoolPartitionLabel = %v, %q, want false, %q", cfg.PoolPartition, cfg.PoolPartitionLabel, v1.LabelTopologyRegion)
+	}
+}
+
+func TestParseLoadBalancerConfigClusterDefaults(t *testing.T) {
+	defaults := config.LoadBalancerConfiguration{
+		PoolNotificationEmail:  "ops@example.com",
+		PoolNotificationFilter: "pool:unhealthy",
+		OriginAddressSources:   "InternalIP,ExternalIP",
+		OriginNodeSelector:     "edge=true",
+	}
+
+	cfg, err := ParseLoadBalancerConfig(newTestService(nil, testPortHTTP), defaults)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{"InternalIP", "ExternalIP"}; !reflect.DeepEqual(cfg.OriginAddressSources, want) {
+		t.Errorf("OriginAddressSources = %v, want %v", cfg.OriginAddressSources, want)
+	}
+
+	if cfg.OriginNodeSelector.String() != "edge=true" {
+		t.Errorf("OriginNodeSelector = %v, want edge=true", cfg.OriginNodeSelector)
+	}
+
+	if cfg.PoolNotificationEmail != "ops@example.com" || cfg.PoolNotificationFilter == nil {
+		t.Errorf("PoolNotificationEmail, PoolNotificationFilter = %q, %+v, want the cluster defaults", cfg.PoolNotificationEmail, cfg.PoolNotificationFilter)
+	}
+
+	// Annotations take precedence over the cluster defaults
+	cfg, err = ParseLoadBalancerConfig(newTestService(map[string]string{
+		serviceAnnotationLoadBalancerOriginAddressSources: "Annotation",
+		serviceAnnotationLoadBalancerOriginNodeSelector:   "",
+	}, testPortHTTP), defaults)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{originAddressSourceAnnotation}; !reflect.DeepEqual(cfg.OriginAddressSources, want) {
+		t.Errorf("OriginAddressSources = %v, want %v", cfg.OriginAddressSources, want)
+	}
+
+	if !cfg.OriginNodeSelector.Empty() {
+		t.Errorf("OriginNodeSelector = %v, want empty", cfg.OriginNodeSelector)
+	}
+}
+
+func TestParseLoadBalancerConfigInvalid(t *testing.T) {
+	tests := []struct {
+		annotation string
+		value      string
+	}{
+		{serviceAnnotationLoadBalancerMonitorType, "udp"},
+		{serviceAnnotationLoadBalancerMonitorMethod, "POST"},
+		{serviceAnnotationLoadBalancerMonitorExpectedCodes, "600"},
+		{serviceAnnotationLoadBalancerMonitorInterval, "1"},
+		{serviceAnnotationLoadBalancerMonitorTimeout, "abc"},
+		{serviceAnnotationLoadBalancerMonitorMode, "health-check-node-port"},
+		{serviceAnnotationLoadBalancerMonitorPort, "https"},
+		{serviceAnnotationLoadBalancerProxied, "maybe"},
+		{serviceAnnotationLoadBalancerTTL, "60"},
+		{serviceAnnotationLoadBalancerSteeringPolicy, "round_robin"},
+		{serviceAnnotationLoadBalancerLocationStrategyMode, "anycast"},
+		{serviceAnnotationLoadBalancerOriginAddressSources, "ExternalIP,PublicIP"},
+		{serviceAnnotationLoadBalancerOriginIPFamilies, "IPv5"},
+		{serviceAnnotationLoadBalancerOriginNodeSelector, "edge in (true"},
+		{serviceAnnotationLoadBalancerOriginWeightSource, "memory"},
+		{serviceAnnotationLoadBalancerPoolCheckRegions, "WNAM,MARS"},
+		{serviceAnnotationLoadBalancerPoolNotificationEmail, "not an email"},
+		{serviceAnnotationLoadBalancerPoolNotificationFilter, "origin:sick"},
+		{serviceAnnotationLoadBalancerPoolPartitionMapping, "continent:EU=eu-west"},
+		{serviceAnnotationLoadBalancerPortHostNames, "http"},
+		{serviceAnnotationLoadBalancerMonitorHeadersSecret, "health-check"},
+		{serviceAnnotationLoadBalancerMonitorHeadersSecretKeys, "X-Token"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.annotation, func(t *testing.T) {
+			service := newTestService(map[string]string{test.annotation: test.value}, testPortHTTP)
+
+			_, err := ParseLoadBalancerConfig(service, config.LoadBalancerConfiguration{})
+			if !errors.Is(err, errLoadBalancerInvalidAnnotation) {
+				t.Fatalf("error = %v, want %v", err, errLoadBalancerInvalidAnnotation)
+			}
+
+			if !strings.Contains(err.Error(), test.annotation) {
+				t.Errorf("error %q doesn't name the annotation %s", err, test.annotation)
+			}
+		})
+	}
+}
+
+func TestParseLoadBalancerConfigAggregatesErrors(t *testing.T) {
+	annotations := map[string]string{
+		serviceAnnotationLoadBalancerMonitorType:           "udp",
+		serviceAnnotationLoadBalancerSteeringPolicy:        "round_robin",
+		serviceAnnotationLoadBalancerOriginWeightSource:    "memory",
+		serviceAnnotationLoadBalancerPoolNotificationEmail: "not an email",
+	}
+
+	_, err := ParseLoadBalancerConfig(newTestService(annotations, testPortHTTP), config.LoadBalancerConfiguration{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("error %v doesn't wrap multiple errors", err)
+	}
+
+	if got := len(joined.Unwrap()); got != len(annotations) {
+		t.Errorf("got %d errors, want %d: %v", got, len(annotations), err)
+	}
+
+	for annotation := range annotations {
+		if !strings.Contains(err.Error(), annotation) {
+			t.Errorf("error %q doesn't name the annotation %s", err, annotation)
+		}
+	}
+}
+
+func TestParseLoadBalancerConfigSessionAffinity(t *testing.T) {
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		timeoutSeconds  int32
+		wantAffinity    string
+		wantTTL         int
+		wantErrContains string
+	}{
+		{
+			name:         "cookie with the minimum TTL",
+			annotations:  map[string]string{serviceAnnotationLoadBalancerSessionAffinity: "cookie", serviceAnnotationLoadBalancerSessionAffinityTTL: "1800"},
+			wantAffinity: "cookie",
+			wantTTL:      1800,
+		},
+		{
+			name:            "cookie TTL below the minimum",
+			annotations:     map[string]string{serviceAnnotationLoadBalancerSessionAffinity: "cookie", serviceAnnotationLoadBalancerSessionAffinityTTL: "60"},
+			wantErrContains: serviceAnnotationLoadBalancerSessionAffinityTTL,
+		},
+		{
+			name:            "ip_cookie TTL above the maximum",
+			annotations:     map[string]string{serviceAnnotationLoadBalancerSessionAffinity: "ip_cookie", serviceAnnotationLoadBalancerSessionAffinityTTL: "604801"},
+			wantErrContains: serviceAnnotationLoadBalancerSessionAffinityTTL,
+		},
+		{
+			name: "header with the maximum TTL",
+			annotations: map[string]string{
+				serviceAnnotationLoadBalancerSessionAffinity:        "header",
+				serviceAnnotationLoadBalancerSessionAffinityTTL:     "3600",
+				serviceAnnotationLoadBalancerSessionAffinityHeaders: "x-session-id",
+			},
+			wantAffinity: "header",
+			wantTTL:      3600,
+		},
+		{
+			name: "header TTL above the maximum",
+			annotations: map[string]string{
+				serviceAnnotationLoadBalancerSessionAffinity:        "header",
+				serviceAnnotationLoadBalancerSessionAffinityTTL:     "7200",
+				serviceAnnotationLoadBalancerSessionAffinityHeaders: "x-session-id",
+			},
+			wantErrContains: serviceAnnotationLoadBalancerSessionAffinityTTL,
+		},
+		{
+			name:            "header without headers",
+			annotations:     map[string]string{serviceAnnotationLoadBalancerSessionAffinity: "header"},
+			wantErrContains: serviceAnnotationLoadBalancerSessionAffinityHeaders,
+		},
+		{
+			name:           "client IP timeout within the range",
+			timeoutSeconds: 10800,
+			wantAffinity:   "ip_cookie",
+			wantTTL:        10800,
+		},
+		{
+			name:            "client IP timeout below the minimum",
+			timeoutSeconds:  600,
+			wantErrContains: "spec.sessionAffinityConfig.clientIP.timeoutSeconds",
+		},
+		{
+			name:         "none ignores the TTL",
+			annotations:  map[string]string{serviceAnnotationLoadBalancerSessionAffinity: "none", serviceAnnotationLoadBalancerSessionAffinityTTL: "60"},
+			wantAffinity: "none",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newTestService(test.annotations, testPortHTTP)
+			if test.timeoutSeconds != 0 {
+				service.Spec.SessionAffinity = v1.ServiceAffinityClientIP
+				service.Spec.SessionAffinityConfig = &v1.SessionAffinityConfig{ClientIP: &v1.ClientIPConfig{TimeoutSeconds: &test.timeoutSeconds}}
+			}
+
+			cfg, err := ParseLoadBalancerConfig(service, config.LoadBalancerConfiguration{})
+			if test.wantErrContains != "" {
+				if !errors.Is(err, errLoadBalancerInvalidAnnotation) || !strings.Contains(err.Error(), test.wantErrContains) {
+					t.Fatalf("error = %v, want an invalid annotation error naming %s", err, test.wantErrContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if cfg.SessionAffinity != test.wantAffinity || cfg.SessionAffinityTTL != test.wantTTL {
+				t.Errorf("SessionAffinity, SessionAffinityTTL = %q, %d, want %q, %d", cfg.SessionAffinity, cfg.SessionAffinityTTL, test.wantAffinity, test.wantTTL)
+			}
+		})
+	}
+}
+
+func TestGetLoadBalancerTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		ports       []v1.ServicePort
+		want        []LoadBalancerTarget
+		wantErr     bool
+	}{
+		{
+			name: "no ports",
+			want: []LoadBalancerTarget{{HostName: "example.com"}},
+		},
+		{
+			name:  "first port by default",
+			ports: []v1.ServicePort{testPortHTTP, testPortGRPC},
+			want:  []LoadBalancerTarget{{HostName: "example.com", Port: &testPortHTTP}},
+		},
+		{
+			name:        "monitor port by name",
+			annotations: map[string]string{serviceAnnotationLoadBalancerMonitorPort: "grpc"},
+			ports:       []v1.ServicePort{testPortHTTP, testPortGRPC},
+			want:        []LoadBalancerTarget{{HostName: "example.com", Port: &testPortGRPC}},
+		},
+		{
+			name:        "monitor port by number",
+			annotations: map[string]string{serviceAnnotationLoadBalancerMonitorPort: "9090"},
+			ports:       []v1.ServicePort{testPortHTTP, testPortGRPC},
+			want:        []LoadBalancerTarget{{HostName: "example.com", Port: &testPortGRPC}},
+		},
+		{
+			name:        "unknown monitor port",
+			annotations: map[string]string{serviceAnnotationLoadBalancerMonitorPort: "9999"},
+			ports:       []v1.ServicePort{testPortHTTP},
+			wantErr:     true,
+		},
+		{
+			name:        "port hostnames disabled",
+			annotations: map[string]string{serviceAnnotationLoadBalancerPortHostNames: "false"},
+			ports:       []v1.ServicePort{testPortHTTP, testPortGRPC},
+			want:        []LoadBalancerTarget{{HostName: "example.com", Port: &testPortHTTP}},
+		},
+		{
+			name:        "port hostnames derived from the hostname",
+			annotations: map[string]string{serviceAnnotationLoadBalancerPortHostNames: "true"},
+			ports:       []v1.ServicePort{testPortHTTP, testPortGRPC, {Port: 8080}},
+			want: []LoadBalancerTarget{
+				{HostName: "example.com", Port: &testPortHTTP},
+				{HostName: "http.example.com", Port: &testPortHTTP},
+				{HostName: "grpc.example.com", Port: &testPortGRPC},
+			},
+		},
+		{
+			name:        "port hostnames mapped",
+			annotations: map[string]string{serviceAnnotationLoadBalancerPortHostNames: "grpc = grpc.example.org"},
+			ports:       []v1.ServicePort{testPortHTTP, testPortGRPC},
+			want: []LoadBalancerTarget{
+				{HostName: "example.com", Port: &testPortHTTP},
+				{HostName: "grpc.example.org", Port: &testPortGRPC},
+			},
+		},
+		{
+			name:        "port hostname of unknown port",
+			annotations: map[string]string{serviceAnnotationLoadBalancerPortHostNames: "admin=admin.example.com"},
+			ports:       []v1.ServicePort{testPortHTTP},
+			wantErr:     true,
+		},
+		{
+			name:        "port hostname used twice",
+			annotations: map[string]string{serviceAnnotationLoadBalancerPortHostNames: "http=a.example.com,grpc=a.example.com"},
+			ports:       []v1.ServicePort{testPortHTTP, testPortGRPC},
+			wantErr:     true,
+		},
+		{
+			name:        "port hostname equal to the hostname",
+			annotations: map[string]string{serviceAnnotationLoadBalancerPortHostNames: "grpc=example.com"},
+			ports:       []v1.ServicePort{testPortHTTP, testPortGRPC},
+			wantErr:     true,
+		},
+		{
+			name:        "malformed port hostname",
+			annotations: map[string]string{serviceAnnotationLoadBalancerPortHostNames: "grpc="},
+			ports:       []v1.ServicePort{testPortGRPC},
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			targets, err := GetLoadBalancerTargets(newTestService(test.annotations, test.ports...))
+			if test.wantErr {
+				if !errors.Is(err, errLoadBalancerInvalidAnnotation) {
+					t.Fatalf("error = %v, want %v", err, errLoadBalancerInvalidAnnotation)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(targets, test.want) {
+				t.Errorf("targets = %+v, want %+v", targets, test.want)
+			}
+		})
+	}
+}
+
+func TestGetLoadBalancerTargetsWithoutHostName(t *testing.T) {
+	service := newTestService(nil, testPortHTTP)
+	delete(service.Annotations, serviceAnnotationLoadBalancerHostName)
+
+	if _, err := GetLoadBalancerTargets(service); !errors.Is(err, errLoadBalancerInvalidAnnotation) {
+		t.Errorf("error = %v, want %v", err, errLoadBalancerInvalidAnnotation)
+	}
+}
+
+func TestGetLoadBalancerOriginIPFamilies(t *testing.T) {
+	singleStack, requireDualStack := v1.IPFamilyPolicySingleStack, v1.IPFamilyPolicyRequireDualStack
+
+	tests := []struct {
+		name          string
+		annotation    string
+		families      []v1.IPFamily
+		policy        *v1.IPFamilyPolicy
+		want          []v1.IPFamily
+		wantDualStack bool
+		wantErr       bool
+	}{
+		{
+			name: "no families prefers IPv4",
+			want: []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol},
+		},
+		{
+			name:     "single stack uses the family of the service",
+			families: []v1.IPFamily{v1.IPv6Protocol},
+			policy:   &singleStack,
+			want:     []v1.IPFamily{v1.IPv6Protocol},
+		},
+		{
+			name:     "missing policy counts as single stack",
+			families: []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol},
+			want:     []v1.IPFamily{v1.IPv4Protocol},
+		},
+		{
+			name:          "dual stack service",
+			families:      []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol},
+			policy:        &requireDualStack,
+			want:          []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol},
+			wantDualStack: true,
+		},
+		{
+			name:       "annotation overrides the service",
+			annotation: "IPv4",
+			families:   []v1.IPFamily{v1.IPv6Protocol},
+			policy:     &singleStack,
+			want:       []v1.IPFamily{v1.IPv4Protocol},
+		},
+		{
+			name:       "prefer IPv6",
+			annotation: "PreferIPv6",
+			want:       []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol},
+		},
+		{
+			name:       "prefer IPv4",
+			annotation: "PreferIPv4",
+			want:       []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol},
+		},
+		{
+			name:          "dual stack annotation",
+			annotation:    "DualStack",
+			want:          []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol},
+			wantDualStack: true,
+		},
+		{
+			name:       "invalid annotation",
+			annotation: "ipv4",
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var annotations map[string]string
+			if test.annotation != "" {
+				annotations = map[string]string{serviceAnnotationLoadBalancerOriginIPFamilies: test.annotation}
+			}
+
+			service := newTestService(annotations)
+			service.Spec.IPFamilies = test.families
+			service.Spec.IPFamilyPolicy = test.policy
+
+			families, dualStack, err := GetLoadBalancerOriginIPFamilies(service)
+			if test.wantErr {
+				if !errors.Is(err, errLoadBalancerInvalidAnnotation) {
+					t.Fatalf("error = %v, want %v", err, errLoadBalancerInvalidAnnotation)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(families, test.want) || dualStack != test.wantDualStack {
+				t.Errorf("got %v, %v, want %v, %v", families, dualStack, test.want, test.wantDualStack)
+			}
+		})
+	}
+}
+
+func TestParseNotificationFilter(t *testing.T) {
+	healthy, unhealthy := true, false
+	notify := &cloudflareClient.LoadBalancerPoolNotificationFilterTarget{}
+	disable := &cloudflareClient.LoadBalancerPoolNotificationFilterTarget{Disable: true}
+
+	tests := []struct {
+		value   string
+		want    *cloudflareClient.LoadBalancerPoolNotificationFilter
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: " , ", want: nil},
+		{value: "origin", want: &cloudflareClient.LoadBalancerPoolNotificationFilter{Origin: notify, Pool: disable}},
+		{value: "origin,pool", want: &cloudflareClient.LoadBalancerPoolNotificationFilter{Origin: notify, Pool: notify}},
+		{
+			value: "pool:healthy",
+			want: &cloudflareClient.LoadBalancerPoolNotificationFilter{
+				Origin: disable,
+				Pool:   &cloudflareClient.LoadBalancerPoolNotificationFilterTarget{Healthy: &healthy},
+			},
+		},
+		{
+			value: "origin:unhealthy, pool",
+			want: &cloudflareClient.LoadBalancerPoolNotificationFilter{
+				Origin: &cloudflareClient.LoadBalancerPoolNotificationFilterTarget{Healthy: &unhealthy},
+				Pool:   notify,
+			},
+		},
+		{value: "origin:healthy,origin:unhealthy", want: &cloudflareClient.LoadBalancerPoolNotificationFilter{Origin: notify, Pool: disable}},
+		{value: "monitor", wantErr: true},
+		{value: "pool:sick", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			filter, err := parseNotificationFilter(test.value)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(filter, test.want) {
+				t.Errorf("filter = %+v, want %+v", filter, test.want)
+			}
+		})
+	}
+}
+
+func TestGetIntAnnotation(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "5", want: 5},
+		{value: "10", want: 10},
+		{value: "4", wantErr: true},
+		{value: "11", wantErr: true},
+		{value: "five", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			service := newTestService(map[string]string{serviceAnnotationLoadBalancerMonitorRetries: test.value})
+
+			got, err := getIntAnnotation(service, serviceAnnotationLoadBalancerMonitorRetries, 7, 5, 10)
+			if test.wantErr {
+				if !errors.Is(err, errLoadBalancerInvalidAnnotation) {
+					t.Fatalf("error = %v, want %v", err, errLoadBalancerInvalidAnnotation)
+				}
+				return
+			}
+
+			if err != nil || got != test.want {
+				t.Errorf("got %d, %v, want %d", got, err, test.want)
+			}
+		})
+	}
+
+	if got, err := getIntAnnotation(newTestService(nil), serviceAnnotationLoadBalancerMonitorRetries, 7, 5, 10); err != nil || got != 7 {
+		t.Errorf("got %d, %v, want the default 7", got, err)
+	}
+}
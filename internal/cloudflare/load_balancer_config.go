@@ -0,0 +1,249 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/internal/config"
+	cloudflareClient "github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/pkg/cloudflare"
+	"github.com/cloudflare/cloudflare-go"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// LoadBalancerConfig is the validated configuration of the load balancer of a service, parsed from its annotations
+type LoadBalancerConfig struct {
+	Targets []LoadBalancerTarget
+	Monitor LoadBalancerMonitorConfig
+
+	Proxied bool
+	TTL     int
+
+	SteeringPolicy              string
+	RandomSteeringDefaultWeight float64
+	RandomSteeringPoolWeights   map[string]float64
+
+	AdaptiveRouting  *cloudflare.AdaptiveRouting
+	LocationStrategy *cloudflare.LocationStrategy
+
+	SessionAffinity           string
+	SessionAffinityTTL        int
+	SessionAffinityAttributes *cloudflare.SessionAffinityAttributes
+
+	OriginAddressSources      []string
+	OriginIPFamilies          []v1.IPFamily
+	OriginDualStack           bool
+	OriginNodeSelector        labels.Selector
+	OriginIncludeControlPlane bool
+	OriginWeightSource        string
+
+	PoolOriginSteering string
+	PoolMinimumOrigins *int
+	PoolLoadShedding   *cloudflare.LoadBalancerLoadShedding
+	PoolCheckRegions   []string
+
+	PoolNotificationEmail  string
+	PoolNotificationFilter *cloudflareClient.LoadBalancerPoolNotificationFilter
+
+	PoolPartition         bool
+	PoolPartitionLabel    string
+	PoolPartitionPriority []string
+	PoolPartitionMapping  PoolPartitionMapping
+}
+
+// LoadBalancerTarget is a hostname with its own load balancer, pools and monitor sending traffic to one port of the service
+type LoadBalancerTarget struct {
+	HostName string
+	// Port is nil when the service has no ports, the monitor then has to probe the health check node port
+	Port *v1.ServicePort
+}
+
+// LoadBalancerMonitorConfig is the validated configuration of the health check monitor
+type LoadBalancerMonitorConfig struct {
+	Mode              string
+	Type              string
+	Method            string
+	Path              string
+	ProbeZone         string
+	ExpectedCodes     string
+	ExpectedBody      string
+	AllowInsecure     bool
+	FollowRedirects   bool
+	Interval          int
+	Timeout           int
+	Retries           int
+	ConsecutiveUp     int
+	ConsecutiveDown   int
+	Headers           map[string][]string
+	HeadersSecret     string
+	HeadersSecretKeys []string
+}
+
+// ParseLoadBalancerConfig parses and validates every annotation of the service, using the cluster wide defaults
+// for settings the service doesn't override. All errors are collected and returned as one so users see every
+// invalid annotation at once
+func ParseLoadBalancerConfig(service *v1.Service, defaults config.LoadBalancerConfiguration) (LoadBalancerConfig, error) {
+	var errs []error
+	var cfg LoadBalancerConfig
+
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var err error
+
+	cfg.Targets, err = GetLoadBalancerTargets(service)
+	collect(err)
+
+	cfg.Monitor, err = parseLoadBalancerMonitorConfig(service)
+	collect(err)
+
+	cfg.Proxied, err = GetLoadBalancerProxied(service)
+	collect(err)
+	cfg.TTL, err = GetLoadBalancerTTL(service)
+	collect(err)
+
+	if _, ok := service.Annotations[serviceAnnotationLoadBalancerTTL]; ok && cfg.Proxied {
+		collect(fmt.Errorf("%w: %s can only be set when %s is false", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerTTL, serviceAnnotationLoadBalancerProxied))
+	}
+
+	cfg.SteeringPolicy, err = GetLoadBalancerSteeringPolicy(service)
+	collect(err)
+	cfg.RandomSteeringDefaultWeight, err = GetLoadBalancerRandomSteeringDefaultWeight(service)
+	collect(err)
+	cfg.RandomSteeringPoolWeights, err = GetLoadBalancerRandomSteeringPoolWeights(service)
+	collect(err)
+
+	cfg.AdaptiveRouting, err = GetLoadBalancerAdaptiveRouting(service)
+	collect(err)
+	cfg.LocationStrategy, err = GetLoadBalancerLocationStrategy(service)
+	collect(err)
+
+	cfg.SessionAffinity, cfg.SessionAffinityTTL, err = GetLoadBalancerSessionAffinity(service)
+	collect(err)
+	cfg.SessionAffinityAttributes, err = GetLoadBalancerSessionAffinityAttributes(service)
+	collect(err)
+	collect(validateLoadBalancerSessionAffinity(service, cfg.SessionAffinity, cfg.SessionAffinityTTL, cfg.SessionAffinityAttributes))
+
+	cfg.OriginAddressSources, err = GetLoadBalancerOriginAddressSources(service, defaults.OriginAddressSources)
+	collect(err)
+
+	cfg.OriginIPFamilies, cfg.OriginDualStack, err = GetLoadBalancerOriginIPFamilies(service)
+	collect(err)
+
+	cfg.OriginNodeSelector, err = GetLoadBalancerOriginNodeSelector(service, defaults.OriginNodeSelector)
+	collect(err)
+
+	cfg.OriginIncludeControlPlane, err = GetLoadBalancerOriginIncludeControlPlane(service)
+	collect(err)
+
+	cfg.OriginWeightSource, err = GetLoadBalancerOriginWeightSource(service)
+	collect(err)
+
+	cfg.PoolOriginSteering, err = GetLoadBalancerPoolOriginSteering(service)
+	collect(err)
+	cfg.PoolMinimumOrigins, err = GetLoadBalancerPoolMinimumOrigins(service)
+	collect(err)
+	cfg.PoolLoadShedding, err = GetLoadBalancerPoolLoadShedding(service)
+	collect(err)
+	cfg.PoolCheckRegions, err = GetLoadBalancerPoolCheckRegions(service)
+	collect(err)
+
+	cfg.PoolNotificationEmail, err = GetLoadBalancerPoolNotificationEmail(service, defaults.PoolNotificationEmail)
+	collect(err)
+	cfg.PoolNotificationFilter, err = GetLoadBalancerPoolNotificationFilter(service, defaults.PoolNotificationFilter)
+	collect(err)
+
+	cfg.PoolPartition, err = GetLoadBalancerPoolPartition(service)
+	collect(err)
+	cfg.PoolPartitionLabel, err = GetLoadBalancerPoolPartitionLabel(service)
+	collect(err)
+	cfg.PoolPartitionPriority, err = GetLoadBalancerPoolPartitionPriority(service)
+	collect(err)
+	cfg.PoolPartitionMapping, err = GetLoadBalancerPoolPartitionMapping(service)
+	collect(err)
+
+	if len(errs) > 0 {
+		return LoadBalancerConfig{}, errors.Join(errs...)
+	}
+
+	return cfg, nil
+}
+
+func parseLoadBalancerMonitorConfig(service *v1.Service) (LoadBalancerMonitorConfig, error) {
+	var errs []error
+	var cfg LoadBalancerMonitorConfig
+
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var err error
+
+	cfg.Mode, err = GetLoadBalancerMonitorMode(service)
+	collect(err)
+	cfg.Type, err = GetLoadBalancerMonitorType(service)
+	collect(err)
+	cfg.Method, err = GetLoadBalancerMonitorMethod(service)
+	collect(err)
+	cfg.Path, err = GetLoadBalancerMonitorPath(service)
+	collect(err)
+	cfg.ProbeZone, err = GetLoadBalancerMonitorProbeZone(service)
+	collect(err)
+	cfg.ExpectedCodes, err = GetLoadBalancerMonitorExpectedCodes(service)
+	collect(err)
+	cfg.ExpectedBody, err = GetLoadBalancerMonitorExpectedBody(service)
+	collect(err)
+	cfg.AllowInsecure, err = GetLoadBalancerMonitorAllowInsecure(service)
+	collect(err)
+	cfg.FollowRedirects, err = GetLoadBalancerMonitorFollowRedirects(service)
+	collect(err)
+	cfg.Interval, err = GetLoadBalancerMonitorInterval(service)
+	collect(err)
+	cfg.Timeout, err = GetLoadBalancerMonitorTimeout(service)
+	collect(err)
+	cfg.Retries, err = GetLoadBalancerMonitorRetries(service)
+	collect(err)
+	cfg.ConsecutiveUp, err = GetLoadBalancerMonitorConsecutiveUp(service)
+	collect(err)
+	cfg.ConsecutiveDown, err = GetLoadBalancerMonitorConsecutiveDown(service)
+	collect(err)
+	cfg.Headers, err = GetLoadBalancerMonitorHeaders(service)
+	collect(err)
+	cfg.HeadersSecret, cfg.HeadersSecretKeys, err = GetLoadBalancerMonitorHeadersSecret(service)
+	collect(err)
+
+	if len(errs) > 0 {
+		return LoadBalancerMonitorConfig{}, errors.Join(errs...)
+	}
+
+	if cfg.Interval <= cfg.Timeout {
+		errs = append(errs, fmt.Errorf("%w: %s must be greater than %s", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorInterval, serviceAnnotationLoadBalancerMonitorTimeout))
+	}
+
+	if cfg.Mode == monitorModeHealthCheckNodePort && service.Spec.HealthCheckNodePort == 0 {
+		errs = append(errs, fmt.Errorf("%w: %s %s requires a health check node port, which is only allocated for externalTrafficPolicy Local", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorMode, cfg.Mode))
+	}
+
+	if cfg.Mode == monitorModeServicePort && len(service.Spec.Ports) == 0 {
+		errs = append(errs, fmt.Errorf("%w: %s %s requires the service to have a port", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorMode, cfg.Mode))
+	}
+
+	if cfg.Type == "tcp" && cfg.Method != "connection_established" {
+		errs = append(errs, fmt.Errorf("%w: %s must be connection_established for tcp monitors, got %q", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorMethod, cfg.Method))
+	}
+
+	if cfg.Type != "tcp" && cfg.Method == "connection_established" {
+		errs = append(errs, fmt.Errorf("%w: %s connection_established is only valid for tcp monitors", errLoadBalancerInvalidAnnotation, serviceAnnotationLoadBalancerMonitorMethod))
+	}
+
+	if len(errs) > 0 {
+		return LoadBalancerMonitorConfig{}, errors.Join(errs...)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,342 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// labelNodeRoleControlPlane and labelNodeRoleMaster mark control plane nodes, the latter on older clusters
+	labelNodeRoleControlPlane = "node-role.kubernetes.io/control-plane"
+	labelNodeRoleMaster       = "node-role.kubernetes.io/master"
+
+	// taintToBeDeletedByClusterAutoscaler is added by the cluster autoscaler to nodes it is about to remove
+	taintToBeDeletedByClusterAutoscaler = "ToBeDeletedByClusterAutoscaler"
+)
+
+var (
+	errEndpointSlicesNotSynced = errors.New("endpoint slice cache has not synced yet")
+)
+
+// buildLoadBalancerPoolOrigins returns the origins of every node of a pool. Nodes without an address of the configured
+// sources are skipped, the pool only fails when none of its nodes has one
+func (l *loadBalancers) buildLoadBalancerPoolOrigins(nodes []*v1.Node, cfg LoadBalancerConfig, weights map[string]float64) ([]cloudflare.LoadBalancerOrigin, error) {
+	var origins []cloudflare.LoadBalancerOrigin
+
+	for _, node := range nodes {
+		nodeOrigins, err := l.buildLoadBalancerOrigins(node, cfg, originWeight(weights, node))
+		if err != nil {
+			klog.Warning(err) // Likely the node doesn't have an address of the sources so skip but log
+			continue
+		}
+
+		origins = append(origins, nodeOrigins...)
+	}
+
+	if len(origins) == 0 && len(nodes) > 0 {
+		return nil, fmt.Errorf("none of the %d nodes of the pool has an address of type %s", len(nodes), strings.Join(cfg.OriginAddressSources, ", "))
+	}
+
+	return origins, nil
+}
+
+// buildLoadBalancerOrigins returns the origins for a node, one per IP family when dual stack is enabled and
+// otherwise one of the first family the node has an address for. Weight is the share of traffic
+// the node receives relative to the other origins of the pool
+func (l *loadBalancers) buildLoadBalancerOrigins(node *v1.Node, cfg LoadBalancerConfig, weight float64) ([]cloudflare.LoadBalancerOrigin, error) {
+
+	var origins []cloudflare.LoadBalancerOrigin
+	var errs []error
+
+	for _, family := range cfg.OriginIPFamilies {
+		address, err := GetNodeOriginAddress(node, cfg.OriginAddressSources, family)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Hostnames match every family but must only be registered once
+		if slices.ContainsFunc(origins, func(origin cloudflare.LoadBalancerOrigin) bool {
+			return origin.Address == address
+		}) {
+			continue
+		}
+
+		origins = append(origins, cloudflare.LoadBalancerOrigin{
+			// Origins are named after the node and family so they stay the same when addresses change
+			Name:    l.client.FormatResourceName(node.Name + "-" + strings.ToLower(string(family))),
+			Address: address,
+			// Draining nodes stay disabled in the pool so cloudflare stops sending traffic before they are removed
+			Enabled: !isDrainingNode(node),
+			Weight:  weight,
+		})
+
+		if !cfg.OriginDualStack {
+			break
+		}
+	}
+
+	if len(origins) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return origins, nil
+}
+
+// eligibleOriginNodes drops the nodes that must never receive traffic: nodes excluded from external load balancers,
+// nodes that aren't ready and control plane nodes unless the service includes them
+func eligibleOriginNodes(cfg LoadBalancerConfig, nodes []*v1.Node) []*v1.Node {
+
+	var eligible []*v1.Node
+
+	for _, node := range nodes {
+		if _, ok := node.Labels[v1.LabelNodeExcludeBalancers]; ok {
+			continue
+		}
+
+		if !isNodeReady(node) {
+			klog.Info("Node ", node.Name, " is not ready, skipping it as origin")
+			continue
+		}
+
+		if !cfg.OriginIncludeControlPlane && isControlPlaneNode(node) {
+			continue
+		}
+
+		eligible = append(eligible, node)
+	}
+
+	return eligible
+}
+
+func isNodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+func isControlPlaneNode(node *v1.Node) bool {
+	_, controlPlane := node.Labels[labelNodeRoleControlPlane]
+	_, master := node.Labels[labelNodeRoleMaster]
+
+	return controlPlane || master
+}
+
+// isDrainingNode reports whether the node is cordoned or about to be removed by the cluster autoscaler
+func isDrainingNode(node *v1.Node) bool {
+	if node.Spec.Unschedulable {
+		return true
+	}
+
+	return slices.ContainsFunc(node.Spec.Taints, func(taint v1.Taint) bool {
+		return taint.Key == taintToBeDeletedByClusterAutoscaler
+	})
+}
+
+// selectOriginNodes returns the nodes matching the origin node selector of the service
+func (o *LoadBalancerOps) selectOriginNodes(service *v1.Service, cfg LoadBalancerConfig, nodes []*v1.Node) ([]*v1.Node, error) {
+
+	if cfg.OriginNodeSelector == nil || cfg.OriginNodeSelector.Empty() {
+		return nodes, nil
+	}
+
+	var selected []*v1.Node
+
+	for _, node := range nodes {
+		if cfg.OriginNodeSelector.Matches(labels.Set(node.Labels)) {
+			selected = append(selected, node)
+		}
+	}
+
+	if len(selected) == 0 {
+		// Keep the current origins rather than emptying the pools
+		err := fmt.Errorf("origin node selector %q matches none of the %d nodes", cfg.OriginNodeSelector, len(nodes))
+		o.recordWarning(service, eventReasonNoOriginNodes, err.Error())
+		return nil, err
+	}
+
+	return selected, nil
+}
+
+// originWeight returns the weight of a node, nodes without an explicit weight get the full weight
+func originWeight(weights map[string]float64, node *v1.Node) float64 {
+	weight, ok := weights[node.Name]
+	if !ok {
+		return 1
+	}
+
+	return weight
+}
+
+// originCapacityWeights returns the weights of the nodes keyed by node name according to the weight source
+// of the service, normalized so the largest node gets the full weight
+func originCapacityWeights(cfg LoadBalancerConfig, nodes []*v1.Node) map[string]float64 {
+
+	if cfg.OriginWeightSource == originWeightSourceNone {
+		return nil
+	}
+
+	capacities := map[string]float64{}
+	maxCapacity := 0.0
+
+	for _, node := range nodes {
+		capacity := nodeCapacity(cfg.OriginWeightSource, node)
+		capacities[node.Name] = capacity
+		maxCapacity = max(maxCapacity, capacity)
+	}
+
+	if maxCapacity == 0 {
+		return nil
+	}
+
+	weights := map[string]float64{}
+	for name, capacity := range capacities {
+		weights[name] = roundWeight(capacity / maxCapacity)
+	}
+
+	return weights
+}
+
+// nodeCapacity returns the relative capacity of a node, invalid or missing values count as 1
+func nodeCapacity(source string, node *v1.Node) float64 {
+
+	switch source {
+	case originWeightSourceCPU:
+		cpu, ok := node.Status.Allocatable[v1.ResourceCPU]
+		if ok && cpu.MilliValue() > 0 {
+			return float64(cpu.MilliValue()) / 1000
+		}
+	case originWeightSourceAnnotation:
+		value, ok := node.Annotations[nodeAnnotationOriginWeight]
+		if !ok {
+			break
+		}
+
+		weight, err := strconv.ParseFloat(value, 64)
+		if err == nil && weight > 0 {
+			return weight
+		}
+
+		klog.Warning("Node ", node.Name, " has an invalid ", nodeAnnotationOriginWeight, " annotation ", value, ", using 1")
+	}
+
+	return 1
+}
+
+// mergeOriginWeights multiplies the weights of the nodes from different sources
+func mergeOriginWeights(a map[string]float64, b map[string]float64) map[string]float64 {
+
+	if a == nil {
+		return b
+	}
+
+	if b == nil {
+		return a
+	}
+
+	weights := map[string]float64{}
+
+	for name, weight := range a {
+		weights[name] = weight
+	}
+
+	for name, weight := range b {
+		if current, ok := weights[name]; ok {
+			weight = roundWeight(current * weight)
+		}
+
+		weights[name] = weight
+	}
+
+	return weights
+}
+
+// roundWeight rounds a weight to the steps of 0.01 between 0.01 and 1 cloudflare accepts
+func roundWeight(weight float64) float64 {
+	return min(max(math.Round(weight*100)/100, 0.01), 1)
+}
+
+// localEndpointNodes narrows the nodes down to the ones hosting ready endpoints when the service uses
+// externalTrafficPolicy Local, as kube-proxy drops traffic on all other nodes. The returned weights
+// are keyed by node name and proportional to the number of local endpoints
+func (o *LoadBalancerOps) localEndpointNodes(service *v1.Service, nodes []*v1.Node) ([]*v1.Node, map[string]float64, error) {
+
+	if service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyLocal {
+		return nodes, nil, nil
+	}
+
+	if o.endpointSliceLister == nil {
+		klog.Warning("Endpoint slices are not watched, using all nodes for service ", service.Name)
+		return nodes, nil, nil
+	}
+
+	if !o.hasSynced() {
+		return nil, nil, errEndpointSlicesNotSynced
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service.Name})
+	endpointSlices, err := o.endpointSliceLister.EndpointSlices(service.Namespace).List(selector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	endpointsPerNode := map[string]int{}
+	maxEndpoints := 0
+
+	for _, endpointSlice := range endpointSlices {
+		for nodeName, count := range readyEndpointsPerNode(endpointSlice) {
+			endpointsPerNode[nodeName] += count
+			maxEndpoints = max(maxEndpoints, endpointsPerNode[nodeName])
+		}
+	}
+
+	var selected []*v1.Node
+	weights := map[string]float64{}
+
+	for _, node := range nodes {
+		count, ok := endpointsPerNode[node.Name]
+		if !ok {
+			continue
+		}
+
+		selected = append(selected, node)
+		weights[node.Name] = roundWeight(float64(count) / float64(maxEndpoints))
+	}
+
+	if len(selected) == 0 {
+		klog.Warning("No node hosts ready endpoints for service ", service.Namespace, "/", service.Name, ", using all nodes")
+		return nodes, nil, nil
+	}
+
+	return selected, weights, nil
+}
+
+// readyEndpointsPerNode counts the ready endpoints of an endpoint slice per node
+func readyEndpointsPerNode(endpointSlice *discoveryv1.EndpointSlice) map[string]int {
+	endpointsPerNode := map[string]int{}
+
+	for _, endpoint := range endpointSlice.Endpoints {
+		// A missing ready condition has to be interpreted as ready
+		if endpoint.NodeName == nil || (endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready) {
+			continue
+		}
+
+		endpointsPerNode[*endpoint.NodeName]++
+	}
+
+	return endpointsPerNode
+}
@@ -0,0 +1,83 @@
+package cloudflare
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestOriginCapacityWeights(t *testing.T) {
+	withCPU := func(name string, cpu string) *v1.Node {
+		node := newTestNode(name, nil)
+		node.Status.Allocatable = v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}
+		return node
+	}
+
+	withWeight := func(name string, weight string) *v1.Node {
+		node := newTestNode(name, nil)
+		node.Annotations = map[string]string{nodeAnnotationOriginWeight: weight}
+		return node
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		nodes  []*v1.Node
+		want   map[string]float64
+	}{
+		{
+			name:   "none",
+			source: originWeightSourceNone,
+			nodes:  []*v1.Node{withCPU("a", "2"), withCPU("b", "4")},
+			want:   nil,
+		},
+		{
+			name:   "cpu",
+			source: originWeightSourceCPU,
+			nodes:  []*v1.Node{withCPU("a", "2"), withCPU("b", "4"), withCPU("c", "500m"), newTestNode("d", nil)},
+			want:   map[string]float64{"a": 0.5, "b": 1, "c": 0.13, "d": 0.25},
+		},
+		{
+			name:   "annotation",
+			source: originWeightSourceAnnotation,
+			nodes:  []*v1.Node{withWeight("a", "3"), withWeight("b", "invalid"), withWeight("c", "-1"), newTestNode("d", nil)},
+			want:   map[string]float64{"a": 1, "b": 0.33, "c": 0.33, "d": 0.33},
+		},
+		{
+			name:   "tiny weights are raised to the minimum",
+			source: originWeightSourceAnnotation,
+			nodes:  []*v1.Node{withWeight("a", "1000"), withWeight("b", "1")},
+			want:   map[string]float64{"a": 1, "b": 0.01},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			weights := originCapacityWeights(LoadBalancerConfig{OriginWeightSource: test.source}, test.nodes)
+
+			if !reflect.DeepEqual(weights, test.want) {
+				t.Errorf("weights = %v, want %v", weights, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeOriginWeights(t *testing.T) {
+	a := map[string]float64{"a": 0.5, "b": 1}
+	b := map[string]float64{"a": 0.5, "c": 0.2}
+
+	if got := mergeOriginWeights(nil, b); !reflect.DeepEqual(got, b) {
+		t.Errorf("mergeOriginWeights(nil, b) = %v, want %v", got, b)
+	}
+
+	if got := mergeOriginWeights(a, nil); !reflect.DeepEqual(got, a) {
+		t.Errorf("mergeOriginWeights(a, nil) = %v, want %v", got, a)
+	}
+
+	want := map[string]float64{"a": 0.25, "b": 1, "c": 0.2}
+	if got := mergeOriginWeights(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeOriginWeights(a, b) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package cloudflare
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/cloudflare/cloudflare-go"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// loadBalancerPoolPartition is a group of nodes sharing the same value of the partition label
+// which are served by a single pool. A service that isn't partitioned has one partition with
+// an empty value holding all nodes
+type loadBalancerPoolPartition struct {
+	value string
+	nodes []*v1.Node
+}
+
+// partitionNodes groups the nodes into partitions ordered by the partition priority of the service,
+// partitions without a priority follow in alphabetical order
+func partitionNodes(cfg LoadBalancerConfig, nodes []*v1.Node) ([]loadBalancerPoolPartition, error) {
+
+	if !cfg.PoolPartition {
+		return []loadBalancerPoolPartition{{nodes: nodes}}, nil
+	}
+
+	label := cfg.PoolPartitionLabel
+	priority := cfg.PoolPartitionPriority
+
+	nodesByValue := map[string][]*v1.Node{}
+
+	for _, node := range nodes {
+		value, ok := node.Labels[label]
+		if !ok || value == "" {
+			klog.Warningf("Node %s has no %s label, skipping it for partitioned pools", node.Name, label)
+			continue
+		}
+
+		nodesByValue[value] = append(nodesByValue[value], node)
+	}
+
+	if len(nodesByValue) == 0 {
+		return nil, fmt.Errorf("no nodes with the %s label found to partition pools", label)
+	}
+
+	values := make([]string, 0, len(nodesByValue))
+	for value := range nodesByValue {
+		values = append(values, value)
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		pi, pj := slices.Index(priority, values[i]), slices.Index(priority, values[j])
+
+		switch {
+		case pi >= 0 && pj >= 0:
+			return pi < pj
+		case pi >= 0:
+			return true
+		case pj >= 0:
+			return false
+		}
+
+		return values[i] < values[j]
+	})
+
+	partitions := make([]loadBalancerPoolPartition, 0, len(values))
+	for _, value := range values {
+		partitions = append(partitions, loadBalancerPoolPartition{value: value, nodes: nodesByValue[value]})
+	}
+
+	return partitions, nil
+}
+
+// buildPoolPartitionMapping resolves the partition values of a region, country or pop mapping to pool IDs.
+// Partitions that currently have no pool are left out
+func (l *loadBalancers) buildPoolPartitionMapping(hostName string, mapping map[string][]string, pools []cloudflare.LoadBalancerPool) map[string][]string {
+
+	result := map[string][]string{}
+
+	for code, values := range mapping {
+		for _, value := range values {
+			poolName := l.getLoadBalancerPartitionPoolName(hostName, value)
+
+			index := slices.IndexFunc(pools, func(pool cloudflare.LoadBalancerPool) bool {
+				return pool.Name == poolName
+			})
+
+			if index < 0 {
+				klog.Warningf("Partition %s mapped to %s has no pool, skipping it", value, code)
+				continue
+			}
+
+			result[code] = append(result[code], pools[index].ID)
+		}
+	}
+
+	return result
+}
+
+// loadBalancerPoolIDs returns the IDs of every pool referenced by the load balancer
+func loadBalancerPoolIDs(loadBalancer cloudflare.LoadBalancer) []string {
+
+	ids := slices.Clone(loadBalancer.DefaultPools)
+	ids = append(ids, loadBalancer.FallbackPool)
+
+	for _, mapping := range []map[string][]string{loadBalancer.RegionPools, loadBalancer.CountryPools, loadBalancer.PopPools} {
+		for _, poolIds := range mapping {
+			ids = append(ids, poolIds...)
+		}
+	}
+
+	slices.Sort(ids)
+
+	return slices.DeleteFunc(slices.Compact(ids), func(id string) bool {
+		return id == ""
+	})
+}
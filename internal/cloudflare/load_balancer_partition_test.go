@@ -0,0 +1,99 @@
+package cloudflare
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestNode returns a node with the given name and labels
+func newTestNode(name string, labels map[string]string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestPartitionNodes(t *testing.T) {
+	region := func(value string) map[string]string {
+		return map[string]string{v1.LabelTopologyRegion: value}
+	}
+
+	nodes := []*v1.Node{
+		newTestNode("a-1", region("eu-west")),
+		newTestNode("b-1", region("us-east")),
+		newTestNode("c-1", region("ap-south")),
+		newTestNode("a-2", region("eu-west")),
+		newTestNode("unlabeled", nil),
+	}
+
+	tests := []struct {
+		name     string
+		cfg      LoadBalancerConfig
+		nodes    []*v1.Node
+		want     map[string][]string
+		wantErr  bool
+		wantKeys []string
+	}{
+		{
+			name:     "not partitioned",
+			cfg:      LoadBalancerConfig{},
+			nodes:    nodes,
+			wantKeys: []string{""},
+			want:     map[string][]string{"": {"a-1", "b-1", "c-1", "a-2", "unlabeled"}},
+		},
+		{
+			name:     "alphabetical without priority",
+			cfg:      LoadBalancerConfig{PoolPartition: true, PoolPartitionLabel: v1.LabelTopologyRegion},
+			nodes:    nodes,
+			wantKeys: []string{"ap-south", "eu-west", "us-east"},
+			want:     map[string][]string{"ap-south": {"c-1"}, "eu-west": {"a-1", "a-2"}, "us-east": {"b-1"}},
+		},
+		{
+			name:     "priority first",
+			cfg:      LoadBalancerConfig{PoolPartition: true, PoolPartitionLabel: v1.LabelTopologyRegion, PoolPartitionPriority: []string{"us-east", "missing"}},
+			nodes:    nodes,
+			wantKeys: []string{"us-east", "ap-south", "eu-west"},
+			want:     map[string][]string{"ap-south": {"c-1"}, "eu-west": {"a-1", "a-2"}, "us-east": {"b-1"}},
+		},
+		{
+			name:    "no labeled nodes",
+			cfg:     LoadBalancerConfig{PoolPartition: true, PoolPartitionLabel: v1.LabelTopologyRegion},
+			nodes:   []*v1.Node{newTestNode("unlabeled", nil)},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			partitions, err := partitionNodes(test.cfg, test.nodes)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var keys []string
+			got := map[string][]string{}
+
+			for _, partition := range partitions {
+				keys = append(keys, partition.value)
+				for _, node := range partition.nodes {
+					got[partition.value] = append(got[partition.value], node.Name)
+				}
+			}
+
+			if !reflect.DeepEqual(keys, test.wantKeys) {
+				t.Errorf("partition order = %v, want %v", keys, test.wantKeys)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("partitions = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package cloudflare
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/internal/config"
+	"github.com/cloudflare/cloudflare-go"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestSecretHeaders(t *testing.T) {
+	newSecret := func(labels map[string]string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "health-check", Labels: labels},
+			Data: map[string][]byte{
+				"x-token":     []byte("secret-token"),
+				"x-tenant":    []byte("tenant"),
+				"db-password": []byte("hunter2"),
+			},
+		}
+	}
+
+	optedIn := map[string]string{secretLabelMonitorHeaders: "true"}
+
+	tests := []struct {
+		name            string
+		secret          *v1.Secret
+		secretName      string
+		keys            []string
+		want            map[string][]string
+		wantErrContains string
+	}{
+		{
+			name: "no secret",
+		},
+		{
+			name:       "only the listed keys",
+			secret:     newSecret(optedIn),
+			secretName: "health-check",
+			keys:       []string{"x-token", "x-tenant"},
+			want:       map[string][]string{"X-Token": {"secret-token"}, "X-Tenant": {"tenant"}},
+		},
+		{
+			name:            "secret without the label",
+			secret:          newSecret(nil),
+			secretName:      "health-check",
+			keys:            []string{"x-token"},
+			wantErrContains: secretLabelMonitorHeaders,
+		},
+		{
+			name:            "label not set to true",
+			secret:          newSecret(map[string]string{secretLabelMonitorHeaders: "false"}),
+			secretName:      "health-check",
+			keys:            []string{"x-token"},
+			wantErrContains: secretLabelMonitorHeaders,
+		},
+		{
+			name:            "missing key",
+			secret:          newSecret(optedIn),
+			secretName:      "health-check",
+			keys:            []string{"x-missing"},
+			wantErrContains: "x-missing",
+		},
+		{
+			name:            "missing secret",
+			secretName:      "health-check",
+			keys:            []string{"x-token"},
+			wantErrContains: "failed to get monitor headers secret",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if test.secret != nil {
+				client = fake.NewSimpleClientset(test.secret)
+			}
+
+			o := &LoadBalancerOps{client: client}
+
+			headers, err := o.secretHeaders(context.Background(), newTestService(nil), test.secretName, test.keys)
+			if test.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErrContains) {
+					t.Fatalf("error = %v, want an error containing %q", err, test.wantErrContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(headers, test.want) {
+				t.Errorf("headers = %v, want %v", headers, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadBalancersUsingPool(t *testing.T) {
+	loadBalancers := []cloudflare.LoadBalancer{
+		{ID: "lb-1", Name: "example.com", DefaultPools: []string{"pool-1", "pool-2"}, FallbackPool: "pool-1"},
+		{ID: "lb-2", Name: "api.example.com", DefaultPools: []string{"pool-3"}, FallbackPool: "pool-2"},
+		{ID: "lb-3", Name: "eu.example.com", DefaultPools: []string{"pool-4"}, RegionPools: map[string][]string{"WEU": {"pool-1"}}},
+	}
+
+	tests := []struct {
+		name     string
+		ignoreId string
+		poolId   string
+		want     []string
+	}{
+		{name: "default pool", poolId: "pool-3", want: []string{"api.example.com"}},
+		{name: "fallback pool", poolId: "pool-2", want: []string{"example.com", "api.example.com"}},
+		{name: "region pool", poolId: "pool-1", want: []string{"example.com", "eu.example.com"}},
+		{name: "ignored load balancer", ignoreId: "lb-1", poolId: "pool-1", want: []string{"eu.example.com"}},
+		{name: "only used by the ignored load balancer", ignoreId: "lb-2", poolId: "pool-3"},
+		{name: "unused pool", poolId: "pool-5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := loadBalancersUsingPool(loadBalancers, test.ignoreId, test.poolId); !slices.Equal(got, test.want) {
+				t.Errorf("loadBalancersUsingPool() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDeleteLoadBalancer(t *testing.T) {
+	marked := func(name string) string {
+		return name + " " + testMarker
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		setup       func(t *testing.T, cloudflareAPI *fakeCloudflare)
+		wantErrs    []string
+		// wantRemained lists the names of the load balancers and pools and the descriptions of the monitors left
+		wantRemained []string
+	}{
+		{
+			name: "deletes the load balancer, every pool and the monitor",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				monitor := cloudflareAPI.add(t, fakeMonitors, cloudflare.LoadBalancerMonitor{Description: marked("example.com-monitor")})
+				pool := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "example.com-eu-pool", Description: testMarker, Monitor: monitor})
+				fallback := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "example.com-us-pool", Description: testMarker, Monitor: monitor})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "example.com", Description: testMarker, DefaultPools: []string{pool, fallback}, FallbackPool: fallback})
+				cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "other.example.com-pool", Description: testMarkerOtherService})
+			},
+			wantRemained: []string{"other.example.com-pool"},
+		},
+		{
+			name:  "already deleted",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {},
+		},
+		{
+			name: "leftovers without load balancer",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				monitor := cloudflareAPI.add(t, fakeMonitors, cloudflare.LoadBalancerMonitor{Description: marked("example.com-monitor")})
+				cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "example.com-pool", Description: testMarker, Monitor: monitor})
+			},
+		},
+		{
+			name: "keeps pools and the monitor still used by another load balancer",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				monitor := cloudflareAPI.add(t, fakeMonitors, cloudflare.LoadBalancerMonitor{Description: marked("example.com-monitor")})
+				pool := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "example.com-pool", Description: testMarker, Monitor: monitor})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "example.com", Description: testMarker, DefaultPools: []string{pool}, FallbackPool: pool})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "www.example.com", Description: testMarkerOtherService, DefaultPools: []string{pool}, FallbackPool: pool})
+			},
+			wantRemained: []string{"www.example.com", "example.com-pool", marked("example.com-monitor")},
+		},
+		{
+			name: "keeps resources of other services",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				pool := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "example.com-pool", Description: testMarkerOtherService})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "example.com", Description: testMarkerOtherService, DefaultPools: []string{pool}, FallbackPool: pool})
+			},
+			wantRemained: []string{"example.com", "example.com-pool"},
+		},
+		{
+			name: "keeps unmarked resources",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				monitor := cloudflareAPI.add(t, fakeMonitors, cloudflare.LoadBalancerMonitor{Description: "example.com-monitor"})
+				cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "example.com-pool", Monitor: monitor})
+			},
+			wantRemained: []string{"example.com-pool", "example.com-monitor"},
+		},
+		{
+			name:        "deletes every port hostname and aggregates failures",
+			annotations: map[string]string{serviceAnnotationLoadBalancerPortHostNames: "http=www.example.com,grpc=grpc.example.com"},
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				for _, hostName := range []string{"www.example.com", "grpc.example.com", "example.com"} {
+					pool := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: hostName + "-pool", Description: testMarker})
+					cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: hostName, Description: testMarker, DefaultPools: []string{pool}, FallbackPool: pool})
+				}
+
+				cloudflareAPI.failures["DELETE pool-1"] = http.StatusConflict
+				cloudflareAPI.failures["DELETE pool-3"] = http.StatusConflict
+			},
+			wantErrs:     []string{"www.example.com", "grpc.example.com"},
+			wantRemained: []string{"www.example.com-pool", "grpc.example.com-pool"},
+		},
+		{
+			name:        "invalid port hostnames fall back to the hostname",
+			annotations: map[string]string{serviceAnnotationLoadBalancerPortHostNames: "http"},
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				pool := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "example.com-pool", Description: testMarker})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "example.com", Description: testMarker, DefaultPools: []string{pool}, FallbackPool: pool})
+			},
+		},
+		{
+			name: "finds load balancers of removed hostnames by their marker",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				pool := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "old.example.com-pool", Description: testMarker})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "old.example.com", Description: testMarker, DefaultPools: []string{pool}, FallbackPool: pool})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "other.example.com", Description: testMarkerOtherUID})
+			},
+			wantRemained: []string{"other.example.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cloudflareAPI, client := newFakeCloudflare(t)
+			test.setup(t, cloudflareAPI)
+
+			l := newLoadbalancers(client, &LoadBalancerOps{
+				defaults: config.LoadBalancerConfiguration{ClusterID: "prod", AdoptUnmarkedResources: true},
+				recorder: record.NewFakeRecorder(100),
+			})
+
+			service := newTestService(test.annotations, testPortHTTP, testPortGRPC)
+			service.UID = "uid-1"
+
+			err := l.deleteLoadBalancer(context.Background(), service)
+			if len(test.wantErrs) == 0 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, want := range test.wantErrs {
+				if err == nil || !strings.Contains(err.Error(), "failed to delete load balancer "+want) {
+					t.Errorf("error = %v, want a failure of %s", err, want)
+				}
+			}
+
+			remained := remainingResourceNames(t, cloudflareAPI)
+			if !reflect.DeepEqual(remained, test.wantRemained) {
+				t.Errorf("remaining resources = %q, want %q", remained, test.wantRemained)
+			}
+		})
+	}
+}
+
+// remainingResourceNames returns the names of the load balancers and pools and the descriptions of the monitors
+// stored by the fake cloudflare API
+func remainingResourceNames(t *testing.T, cloudflareAPI *fakeCloudflare) []string {
+	t.Helper()
+
+	cloudflareAPI.mu.Lock()
+	defer cloudflareAPI.mu.Unlock()
+
+	var names []string
+	for _, kind := range []string{fakeLoadBalancers, fakePools} {
+		for _, resource := range cloudflareAPI.resources[kind] {
+			names = append(names, resource["name"].(string))
+		}
+	}
+
+	for _, resource := range cloudflareAPI.resources[fakeMonitors] {
+		names = append(names, resource["description"].(string))
+	}
+
+	return names
+}
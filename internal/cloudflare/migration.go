@@ -0,0 +1,61 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// migrateLoadBalancerHostNames deletes the load balancers, pools and monitors of hostnames the service was
+// previously provisioned for, e.g after its hostname annotation changed. Previous hostnames are remembered
+// through the ownership marker of the load balancers, so this must only run once the load balancers of the
+// current hostnames exist. Failures are recorded as events and retried on the next reconcile of the service
+func (l *loadBalancers) migrateLoadBalancerHostNames(ctx context.Context, service *v1.Service, cfg LoadBalancerConfig) {
+
+	previous, err := l.previousLoadBalancerHostNames(ctx, service, cfg)
+	if err != nil {
+		klog.Warning("Failed to look up previous hostnames of service ", service.Namespace, "/", service.Name, ": ", err)
+		return
+	}
+
+	for _, hostName := range previous {
+		l.lbOps.recordNormal(service, eventReasonHostnameMigrating, fmt.Sprintf("Deleting load balancer of previous hostname %s", hostName))
+
+		err := l.deleteLoadBalancerTarget(ctx, service, hostName)
+		if err != nil {
+			klog.Warning("Failed to delete load balancer of previous hostname ", hostName, ": ", err)
+			l.lbOps.recordWarning(service, eventReasonHostnameMigrationFailed, fmt.Sprintf("Failed to delete load balancer of previous hostname %s: %v", hostName, err))
+			continue
+		}
+
+		l.lbOps.recordNormal(service, eventReasonHostnameMigrated, fmt.Sprintf("Deleted load balancer of previous hostname %s", hostName))
+	}
+}
+
+// previousLoadBalancerHostNames returns the hostnames of the load balancers marked for the service that aren't among
+// its current hostnames. Only the marker of exactly this service counts, including its UID, so load balancers of a
+// service of the same name in another cluster, or unmarked ones, are never deleted here
+func (l *loadBalancers) previousLoadBalancerHostNames(ctx context.Context, service *v1.Service, cfg LoadBalancerConfig) ([]string, error) {
+
+	marked, err := l.markedLoadBalancerHostNames(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostNames []string
+
+	for _, hostName := range marked {
+		current := slices.ContainsFunc(cfg.Targets, func(target LoadBalancerTarget) bool {
+			return hostName == l.client.FormatResourceName(target.HostName)
+		})
+
+		if !current {
+			hostNames = append(hostNames, hostName)
+		}
+	}
+
+	return hostNames, nil
+}
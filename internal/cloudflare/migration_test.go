@@ -0,0 +1,183 @@
+package cloudflare
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/internal/config"
+	"github.com/cloudflare/cloudflare-go"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestMigrateLoadBalancerHostNames(t *testing.T) {
+	tests := []struct {
+		name         string
+		setup        func(t *testing.T, cloudflareAPI *fakeCloudflare)
+		wantEvents   []string
+		wantRemained []string
+	}{
+		{
+			name: "current hostname only",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "example.com", Description: testMarker})
+			},
+			wantRemained: []string{"example.com"},
+		},
+		{
+			name: "previous hostname",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "example.com", Description: testMarker})
+				pool := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "old.example.com-pool", Description: testMarker})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "old.example.com", Description: testMarker, DefaultPools: []string{pool}, FallbackPool: pool})
+			},
+			wantEvents: []string{
+				"Normal HostnameMigrating Deleting load balancer of previous hostname old.example.com",
+				"Normal HostnameMigrated Deleted load balancer of previous hostname old.example.com",
+			},
+			wantRemained: []string{"example.com"},
+		},
+		{
+			name: "failed deletion is retried later",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				pool := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "old.example.com-pool", Description: testMarker})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{ID: "lb-old", Name: "old.example.com", Description: testMarker, DefaultPools: []string{pool}, FallbackPool: pool})
+				cloudflareAPI.failures["DELETE lb-old"] = http.StatusConflict
+			},
+			wantEvents: []string{
+				"Normal HostnameMigrating Deleting load balancer of previous hostname old.example.com",
+				"Warning HostnameMigrationFailed Failed to delete load balancer of previous hostname old.example.com",
+			},
+			wantRemained: []string{"old.example.com", "old.example.com-pool"},
+		},
+		{
+			name: "load balancers of other services and unmarked ones are kept",
+			setup: func(t *testing.T, cloudflareAPI *fakeCloudflare) {
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "recreated.example.com", Description: testMarkerOtherUID})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "api.example.com", Description: testMarkerOtherService})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "other-cluster.example.com", Description: testMarkerOtherCluster})
+				cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "hand-made.example.com"})
+			},
+			wantRemained: []string{"recreated.example.com", "api.example.com", "other-cluster.example.com", "hand-made.example.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cloudflareAPI, client := newFakeCloudflare(t)
+			test.setup(t, cloudflareAPI)
+
+			recorder := record.NewFakeRecorder(100)
+			l := newLoadbalancers(client, &LoadBalancerOps{
+				defaults: config.LoadBalancerConfiguration{ClusterID: "prod", AdoptUnmarkedResources: true},
+				recorder: recorder,
+			})
+
+			service := newTestService(nil, testPortHTTP)
+			service.UID = "uid-1"
+
+			cfg, err := ParseLoadBalancerConfig(service, l.lbOps.defaults)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			l.migrateLoadBalancerHostNames(context.Background(), service, cfg)
+
+			if events := recordedEvents(recorder); !eventsHavePrefixes(events, test.wantEvents) {
+				t.Errorf("events = %q, want %q", events, test.wantEvents)
+			}
+
+			if remained := remainingResourceNames(t, cloudflareAPI); !reflect.DeepEqual(remained, test.wantRemained) {
+				t.Errorf("remaining resources = %q, want %q", remained, test.wantRemained)
+			}
+		})
+	}
+}
+
+func TestEnsureLoadBalancerMigratesHostName(t *testing.T) {
+	cloudflareAPI, client := newFakeCloudflare(t)
+
+	monitor := cloudflareAPI.add(t, fakeMonitors, cloudflare.LoadBalancerMonitor{Description: "old.example.com-monitor " + testMarker})
+	pool := cloudflareAPI.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "old.example.com-pool", Description: testMarker, Monitor: monitor})
+	cloudflareAPI.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "old.example.com", Description: testMarker, DefaultPools: []string{pool}, FallbackPool: pool})
+
+	recorder := record.NewFakeRecorder(100)
+	l := newLoadbalancers(client, &LoadBalancerOps{
+		defaults: config.LoadBalancerConfiguration{ClusterID: "prod"},
+		recorder: recorder,
+	})
+
+	service := newTestService(nil, testPortHTTP)
+	service.UID = "uid-1"
+
+	node := newTestNode("node-a", nil)
+	node.Status.Addresses = []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "203.0.113.10"}}
+	node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+
+	status, err := l.EnsureLoadBalancer(context.Background(), "kubernetes", service, []*v1.Node{node})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(status.Ingress) != 1 || status.Ingress[0].Hostname != "example.com" {
+		t.Errorf("status = %+v, want the hostname example.com", status)
+	}
+
+	// The new hostname is announced before the resources of the previous one are deleted
+	want := []string{
+		"Normal HostnameProvisioned Created load balancer for hostname example.com",
+		"Normal HostnameMigrating Deleting load balancer of previous hostname old.example.com",
+		"Normal HostnameMigrated Deleted load balancer of previous hostname old.example.com",
+	}
+
+	if events := recordedEvents(recorder); !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+
+	for _, name := range remainingResourceNames(t, cloudflareAPI) {
+		if strings.HasPrefix(name, "old.example.com") {
+			t.Errorf("resource %q of the previous hostname wasn't deleted", name)
+		}
+	}
+
+	// A second reconcile neither creates nor migrates anything
+	if _, err := l.EnsureLoadBalancer(context.Background(), "kubernetes", service, []*v1.Node{node}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if events := recordedEvents(recorder); len(events) > 0 {
+		t.Errorf("unexpected events on the second reconcile %q", events)
+	}
+}
+
+// recordedEvents drains the events recorded by the fake recorder
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// eventsHavePrefixes reports whether every event starts with the prefix at the same index
+func eventsHavePrefixes(events []string, prefixes []string) bool {
+	if len(events) != len(prefixes) {
+		return false
+	}
+
+	for i, prefix := range prefixes {
+		if !strings.HasPrefix(events[i], prefix) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,189 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ownershipManagedBy, ownershipCluster, ownershipService, ownershipUID and ownershipVersion are the
+	// keys of the ownership marker stored in the description of every resource created by the controller
+	ownershipManagedBy = "managed-by"
+	ownershipCluster   = "cluster"
+	ownershipService   = "service"
+	ownershipUID       = "uid"
+	ownershipVersion   = "version"
+)
+
+var (
+	errResourceNotOwned = errors.New("resource is not owned by this service")
+)
+
+// ownershipDescription returns the ownership marker of the resources of the service
+// e.g managed-by=cloudflare-cloud-controller-manager cluster=kubernetes service=default/web uid=... version=v1.0.0
+func (o *LoadBalancerOps) ownershipDescription(service *v1.Service) string {
+	return strings.Join([]string{
+		ownershipManagedBy + "=" + eventComponent,
+		ownershipCluster + "=" + o.clusterID(),
+		ownershipService + "=" + service.Namespace + "/" + service.Name,
+		ownershipUID + "=" + string(service.UID),
+		ownershipVersion + "=" + providerVersion,
+	}, " ")
+}
+
+// parseOwnershipDescription returns the fields of the ownership marker in a description, nil if it has none
+func parseOwnershipDescription(description string) map[string]string {
+	fields := map[string]string{}
+
+	for _, field := range strings.Fields(description) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			fields[key] = value
+		}
+	}
+
+	if fields[ownershipManagedBy] != eventComponent {
+		return nil
+	}
+
+	return fields
+}
+
+// isOwnedBy reports whether a resource with the given description belongs to the service. The UID is not
+// compared so a recreated service takes over the resources of its predecessor. Resources without any marker
+// were created by versions before markers were introduced and are adopted unless disabled. They are only ever
+// looked up by the names derived from the service, and get the marker of the service on their next update.
+// Adoption only applies to updates, see checkDeletable
+func (o *LoadBalancerOps) isOwnedBy(service *v1.Service, description string) bool {
+	fields := parseOwnershipDescription(description)
+	if fields == nil {
+		return o.defaults.AdoptUnmarkedResources
+	}
+
+	return o.isOwnCluster(fields[ownershipCluster]) && fields[ownershipService] == service.Namespace+"/"+service.Name
+}
+
+// isMarkedFor reports whether a resource carries the marker of exactly this service, including its UID. Unlike
+// isOwnedBy it is safe for finding resources by marker alone, as a service of the same name in another cluster
+// sharing the cluster ID has another UID
+func (o *LoadBalancerOps) isMarkedFor(service *v1.Service, description string) bool {
+	fields := parseOwnershipDescription(description)
+
+	return fields != nil &&
+		o.isOwnCluster(fields[ownershipCluster]) &&
+		fields[ownershipService] == service.Namespace+"/"+service.Name &&
+		fields[ownershipUID] == string(service.UID)
+}
+
+// markedLoadBalancerHostNames returns the hostnames of every load balancer carrying the marker of the service
+func (l *loadBalancers) markedLoadBalancerHostNames(ctx context.Context, service *v1.Service) ([]string, error) {
+
+	loadBalancers, err := l.client.ListLoadBalancers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostNames []string
+
+	for _, loadBalancer := range loadBalancers {
+		if l.lbOps.isMarkedFor(service, loadBalancer.Description) {
+			hostNames = append(hostNames, loadBalancer.Name)
+		}
+	}
+
+	return hostNames, nil
+}
+
+// checkOwnership returns an error and records an event on the service when a resource doesn't belong to it
+func (o *LoadBalancerOps) checkOwnership(service *v1.Service, kind string, name string, description string) error {
+	if o.isOwnedBy(service, description) {
+		return nil
+	}
+
+	return o.notOwnedError(service, "modify", kind, name, description)
+}
+
+// checkDeletable is checkOwnership for deleting a resource. Unmarked resources are never adopted for deletion, as
+// nothing but their name ties them to the service. They are deleted once an update of the service marked them
+func (o *LoadBalancerOps) checkDeletable(service *v1.Service, kind string, name string, description string) error {
+	if parseOwnershipDescription(description) != nil && o.isOwnedBy(service, description) {
+		return nil
+	}
+
+	return o.notOwnedError(service, "delete", kind, name, description)
+}
+
+// notOwnedError returns an error and records an event on the service for refusing an action on a resource
+func (o *LoadBalancerOps) notOwnedError(service *v1.Service, action string, kind string, name string, description string) error {
+	err := fmt.Errorf("%w: refusing to %s %s %s with description %q", errResourceNotOwned, action, kind, name, description)
+	o.recordWarning(service, eventReasonResourceNotOwned, err.Error())
+
+	return err
+}
+
+// clusterID returns the cluster written to and expected in ownership markers. CLOUDFLARE_CLUSTER_ID takes precedence
+// over the cluster name the service controller passes, which is the --cluster-name of the controller manager
+func (o *LoadBalancerOps) clusterID() string {
+	if o.defaults.ClusterID != "" {
+		return o.defaults.ClusterID
+	}
+
+	o.clusterNameMu.RLock()
+	defer o.clusterNameMu.RUnlock()
+
+	if o.clusterName == "" {
+		return defaultClusterID
+	}
+
+	return o.clusterName
+}
+
+// isOwnCluster reports whether the cluster of a marker is this cluster. Before CLOUDFLARE_CLUSTER_ID is set markers
+// carry the cluster name, so it is accepted too and setting the cluster ID on an existing install keeps every
+// resource owned. The resources get the new cluster ID with their next update
+func (o *LoadBalancerOps) isOwnCluster(cluster string) bool {
+	if cluster == o.clusterID() {
+		return true
+	}
+
+	if o.defaults.ClusterID == "" {
+		return false
+	}
+
+	o.clusterNameMu.RLock()
+	defer o.clusterNameMu.RUnlock()
+
+	if o.clusterName == "" {
+		return cluster == defaultClusterID
+	}
+
+	return cluster == o.clusterName
+}
+
+// observeClusterName remembers the cluster name the service controller passes, so controllers that
+// aren't called by it, like the endpoint slice controller, mark resources the same way
+func (o *LoadBalancerOps) observeClusterName(clusterName string) {
+	if clusterName == "" {
+		return
+	}
+
+	o.clusterNameMu.Lock()
+	defer o.clusterNameMu.Unlock()
+
+	o.clusterName = clusterName
+}
+
+// clusterIDKnown reports whether the cluster ID is configured or the service controller passed its cluster name yet
+func (o *LoadBalancerOps) clusterIDKnown() bool {
+	if o.defaults.ClusterID != "" {
+		return true
+	}
+
+	o.clusterNameMu.RLock()
+	defer o.clusterNameMu.RUnlock()
+
+	return o.clusterName != ""
+}
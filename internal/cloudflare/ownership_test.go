@@ -0,0 +1,186 @@
+package cloudflare
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/internal/config"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	testMarker              = "managed-by=cloudflare-cloud-controller-manager cluster=prod service=default/web uid=uid-1 version=v1.0.0"
+	testMarkerOtherUID      = "managed-by=cloudflare-cloud-controller-manager cluster=prod service=default/web uid=uid-2 version=v1.0.0"
+	testMarkerOtherService  = "managed-by=cloudflare-cloud-controller-manager cluster=prod service=default/api uid=uid-1 version=v1.0.0"
+	testMarkerOtherCluster  = "managed-by=cloudflare-cloud-controller-manager cluster=staging service=default/web uid=uid-1 version=v1.0.0"
+	testMarkerOtherProvider = "managed-by=someone-else cluster=prod service=default/web uid=uid-1"
+)
+
+func TestParseOwnershipDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        map[string]string
+	}{
+		{
+			name:        "marker",
+			description: testMarker,
+			want: map[string]string{
+				ownershipManagedBy: eventComponent,
+				ownershipCluster:   "prod",
+				ownershipService:   "default/web",
+				ownershipUID:       "uid-1",
+				ownershipVersion:   "v1.0.0",
+			},
+		},
+		{
+			name:        "monitor name before the marker",
+			description: "example.com-monitor " + testMarker,
+			want: map[string]string{
+				ownershipManagedBy: eventComponent,
+				ownershipCluster:   "prod",
+				ownershipService:   "default/web",
+				ownershipUID:       "uid-1",
+				ownershipVersion:   "v1.0.0",
+			},
+		},
+		{name: "empty", description: ""},
+		{name: "hand made description", description: "production load balancer"},
+		{name: "marker of another controller", description: testMarkerOtherProvider},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseOwnershipDescription(test.description); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseOwnershipDescription() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOwnershipDescriptionRoundTrip(t *testing.T) {
+	o := &LoadBalancerOps{defaults: config.LoadBalancerConfiguration{ClusterID: "prod"}}
+	service := newTestService(nil)
+	service.UID = "uid-1"
+
+	description := o.ownershipDescription(service)
+
+	if !o.isOwnedBy(service, description) || !o.isMarkedFor(service, description) {
+		t.Errorf("service doesn't own its own marker %q", description)
+	}
+}
+
+func TestCheckOwnership(t *testing.T) {
+	tests := []struct {
+		name          string
+		description   string
+		adopt         bool
+		wantModify    bool
+		wantDelete    bool
+		wantMarkedFor bool
+	}{
+		{name: "marked for the service", description: testMarker, wantModify: true, wantDelete: true, wantMarkedFor: true},
+		{name: "recreated service", description: testMarkerOtherUID, wantModify: true, wantDelete: true},
+		{name: "other service", description: testMarkerOtherService},
+		{name: "other cluster", description: testMarkerOtherCluster},
+		{name: "unmarked with adoption", description: "", adopt: true, wantModify: true},
+		{name: "unmarked without adoption", description: ""},
+		{name: "marker of another controller with adoption", description: testMarkerOtherProvider, adopt: true, wantModify: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			o := &LoadBalancerOps{
+				defaults: config.LoadBalancerConfiguration{ClusterID: "prod", AdoptUnmarkedResources: test.adopt},
+				recorder: recorder,
+			}
+
+			service := newTestService(nil)
+			service.UID = "uid-1"
+
+			err := o.checkOwnership(service, "pool", "example.com-pool", test.description)
+			if (err == nil) != test.wantModify {
+				t.Errorf("checkOwnership() = %v, want allowed %v", err, test.wantModify)
+			}
+
+			err = o.checkDeletable(service, "pool", "example.com-pool", test.description)
+			if (err == nil) != test.wantDelete {
+				t.Errorf("checkDeletable() = %v, want allowed %v", err, test.wantDelete)
+			}
+
+			if err != nil && !errors.Is(err, errResourceNotOwned) {
+				t.Errorf("checkDeletable() = %v, want %v", err, errResourceNotOwned)
+			}
+
+			if got := o.isMarkedFor(service, test.description); got != test.wantMarkedFor {
+				t.Errorf("isMarkedFor() = %v, want %v", got, test.wantMarkedFor)
+			}
+
+			refused := 0
+			if !test.wantModify {
+				refused++
+			}
+			if !test.wantDelete {
+				refused++
+			}
+
+			if len(recorder.Events) != refused {
+				t.Errorf("recorded %d events, want %d", len(recorder.Events), refused)
+			}
+		})
+	}
+}
+
+func TestIsOwnCluster(t *testing.T) {
+	tests := []struct {
+		name        string
+		clusterID   string
+		clusterName string
+		cluster     string
+		want        bool
+	}{
+		{name: "cluster name", clusterName: "prod", cluster: "prod", want: true},
+		{name: "default cluster name", cluster: defaultClusterID, want: true},
+		{name: "other cluster name", clusterName: "prod", cluster: "staging"},
+		{name: "cluster ID", clusterID: "prod-eu", clusterName: "prod", cluster: "prod-eu", want: true},
+		{name: "cluster name before the cluster ID was set", clusterID: "prod-eu", clusterName: "prod", cluster: "prod", want: true},
+		{name: "default cluster name before the cluster ID was set", clusterID: "prod-eu", cluster: defaultClusterID, want: true},
+		{name: "other cluster with a cluster ID", clusterID: "prod-eu", clusterName: "prod", cluster: "staging"},
+		{name: "default cluster name once the cluster name is known", clusterID: "prod-eu", clusterName: "prod", cluster: defaultClusterID},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := &LoadBalancerOps{defaults: config.LoadBalancerConfiguration{ClusterID: test.clusterID}}
+			o.observeClusterName(test.clusterName)
+
+			if got := o.isOwnCluster(test.cluster); got != test.want {
+				t.Errorf("isOwnCluster(%q) = %v, want %v", test.cluster, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSettingClusterIDRemarksResources(t *testing.T) {
+	service := newTestService(nil)
+	service.UID = "uid-1"
+
+	before := &LoadBalancerOps{}
+	before.observeClusterName("prod")
+	description := before.ownershipDescription(service)
+
+	after := &LoadBalancerOps{defaults: config.LoadBalancerConfiguration{ClusterID: "prod-eu"}}
+	after.observeClusterName("prod")
+
+	if !after.isOwnedBy(service, description) || !after.isMarkedFor(service, description) {
+		t.Fatalf("resource marked %q is no longer owned after setting the cluster ID", description)
+	}
+
+	// The new marker differs, so the next update of the service writes it
+	remarked := after.ownershipDescription(service)
+	if remarked == description || parseOwnershipDescription(remarked)[ownershipCluster] != "prod-eu" {
+		t.Errorf("marker %q isn't updated to the cluster ID", remarked)
+	}
+}
@@ -0,0 +1,76 @@
+package cloudflare
+
+import (
+	"context"
+
+	cloudflareClient "github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/pkg/cloudflare"
+	"k8s.io/klog/v2"
+)
+
+// createdResources tracks the IDs of the resources created by one reconcile attempt, so a failed attempt
+// can delete them again instead of leaving them behind. A nil tracker records nothing
+type createdResources struct {
+	monitors      []string
+	pools         []string
+	loadBalancers []string
+
+	// loadBalancerHostNames are the hostnames of the created load balancers
+	loadBalancerHostNames []string
+}
+
+func (c *createdResources) addMonitor(id string) {
+	if c != nil {
+		c.monitors = append(c.monitors, id)
+	}
+}
+
+func (c *createdResources) addPool(id string) {
+	if c != nil {
+		c.pools = append(c.pools, id)
+	}
+}
+
+func (c *createdResources) addLoadBalancer(id string, hostName string) {
+	if c != nil {
+		c.loadBalancers = append(c.loadBalancers, id)
+		c.loadBalancerHostNames = append(c.loadBalancerHostNames, hostName)
+	}
+}
+
+// rollback deletes the created resources in reverse dependency order. Failures are only logged,
+// the garbage collector removes whatever is left once the grace period passed
+func (c *createdResources) rollback(ctx context.Context, client *cloudflareClient.CloudflareAPI) {
+	if c == nil {
+		return
+	}
+
+	// The reconcile may have failed because its context was cancelled, the rollback must still run
+	ctx = context.WithoutCancel(ctx)
+
+	for _, id := range c.loadBalancers {
+		if err := client.DeleteLoadBalancerByID(ctx, id); err != nil && !cloudflareClient.IsNotFound(err) {
+			klog.Warning("Failed to roll back load balancer ", id, ": ", err)
+			continue
+		}
+
+		klog.Info("Rolled back load balancer ", id)
+	}
+
+	for _, id := range c.pools {
+		if err := client.DeleteLoadBalancerPoolByID(ctx, id); err != nil && !cloudflareClient.IsNotFound(err) {
+			klog.Warning("Failed to roll back load balancer pool ", id, ": ", err)
+			continue
+		}
+
+		klog.Info("Rolled back load balancer pool ", id)
+	}
+
+	for _, id := range c.monitors {
+		if err := client.DeleteLoadBalancerMonitorByID(ctx, id); err != nil && !cloudflareClient.IsNotFound(err) {
+			klog.Warning("Failed to roll back load balancer monitor ", id, ": ", err)
+			continue
+		}
+
+		klog.Info("Rolled back load balancer monitor ", id)
+	}
+}
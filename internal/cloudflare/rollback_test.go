@@ -0,0 +1,149 @@
+package cloudflare
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name         string
+		created      func(lb, pool1, pool2, monitor string) *createdResources
+		failures     map[string]int
+		cancel       bool
+		wantDeletes  []string
+		wantRemained map[string][]string
+	}{
+		{
+			name: "deletes in reverse dependency order",
+			created: func(lb, pool1, pool2, monitor string) *createdResources {
+				created := &createdResources{}
+				created.addMonitor(monitor)
+				created.addPool(pool1)
+				created.addPool(pool2)
+				created.addLoadBalancer(lb, "example.com")
+				return created
+			},
+			wantDeletes: []string{
+				"DELETE load_balancers load_balancer-1",
+				"DELETE pools pool-2",
+				"DELETE pools pool-3",
+				"DELETE monitors monitor-4",
+			},
+			wantRemained: map[string][]string{},
+		},
+		{
+			name: "only created resources",
+			created: func(lb, pool1, pool2, monitor string) *createdResources {
+				created := &createdResources{}
+				created.addPool(pool2)
+				return created
+			},
+			wantDeletes: []string{"DELETE pools pool-3"},
+			wantRemained: map[string][]string{
+				fakeLoadBalancers: {"load_balancer-1"},
+				fakePools:         {"pool-2"},
+				fakeMonitors:      {"monitor-4"},
+			},
+		},
+		{
+			name: "resources deleted in the meantime",
+			created: func(lb, pool1, pool2, monitor string) *createdResources {
+				created := &createdResources{}
+				created.addPool("pool-gone")
+				created.addMonitor(monitor)
+				return created
+			},
+			wantDeletes: []string{"DELETE pools pool-gone", "DELETE monitors monitor-4"},
+			wantRemained: map[string][]string{
+				fakeLoadBalancers: {"load_balancer-1"},
+				fakePools:         {"pool-2", "pool-3"},
+			},
+		},
+		{
+			name: "failures don't stop the rollback",
+			created: func(lb, pool1, pool2, monitor string) *createdResources {
+				created := &createdResources{}
+				created.addMonitor(monitor)
+				created.addPool(pool1)
+				created.addPool(pool2)
+				created.addLoadBalancer(lb, "example.com")
+				return created
+			},
+			failures: map[string]int{"DELETE pool-2": http.StatusConflict},
+			wantDeletes: []string{
+				"DELETE load_balancers load_balancer-1",
+				"DELETE pools pool-2",
+				"DELETE pools pool-3",
+				"DELETE monitors monitor-4",
+			},
+			wantRemained: map[string][]string{fakePools: {"pool-2"}},
+		},
+		{
+			name: "cancelled context",
+			created: func(lb, pool1, pool2, monitor string) *createdResources {
+				created := &createdResources{}
+				created.addLoadBalancer(lb, "example.com")
+				return created
+			},
+			cancel:      true,
+			wantDeletes: []string{"DELETE load_balancers load_balancer-1"},
+			wantRemained: map[string][]string{
+				fakePools:    {"pool-2", "pool-3"},
+				fakeMonitors: {"monitor-4"},
+			},
+		},
+		{
+			name: "nil tracker",
+			created: func(lb, pool1, pool2, monitor string) *createdResources {
+				var created *createdResources
+				created.addLoadBalancer(lb, "example.com")
+				return created
+			},
+			wantRemained: map[string][]string{
+				fakeLoadBalancers: {"load_balancer-1"},
+				fakePools:         {"pool-2", "pool-3"},
+				fakeMonitors:      {"monitor-4"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, client := newFakeCloudflare(t)
+			lb := fake.add(t, fakeLoadBalancers, cloudflare.LoadBalancer{Name: "example.com"})
+			pool1 := fake.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "example-com-pool"})
+			pool2 := fake.add(t, fakePools, cloudflare.LoadBalancerPool{Name: "example-com-pool-eu"})
+			monitor := fake.add(t, fakeMonitors, cloudflare.LoadBalancerMonitor{Description: "example-com-monitor"})
+			fake.failures = test.failures
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.cancel {
+				cancel()
+			} else {
+				defer cancel()
+			}
+
+			test.created(lb, pool1, pool2, monitor).rollback(ctx, client)
+
+			if got := fake.requested(http.MethodDelete); !reflect.DeepEqual(got, test.wantDeletes) {
+				t.Errorf("deletes = %v, want %v", got, test.wantDeletes)
+			}
+
+			remained := map[string][]string{}
+			for _, kind := range []string{fakeLoadBalancers, fakePools, fakeMonitors} {
+				if ids := fake.ids(kind); len(ids) > 0 {
+					remained[kind] = ids
+				}
+			}
+
+			if !reflect.DeepEqual(remained, test.wantRemained) {
+				t.Errorf("remaining resources = %v, want %v", remained, test.wantRemained)
+			}
+		})
+	}
+}
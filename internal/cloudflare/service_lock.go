@@ -0,0 +1,44 @@
+package cloudflare
+
+import "sync"
+
+// serviceLock serializes the reconciles of a single service
+type serviceLock struct {
+	sync.Mutex
+	users int
+}
+
+// lockService blocks until no other reconcile of the service runs and returns the function releasing the lock.
+// The service controller and the endpoint slice controller reconcile services concurrently, without the lock
+// both could create the monitor and pools of a new service, or recreate them while the service is deleted
+func (o *LoadBalancerOps) lockService(namespace string, name string) func() {
+	key := namespace + "/" + name
+
+	o.serviceLocksMu.Lock()
+	if o.serviceLocks == nil {
+		o.serviceLocks = map[string]*serviceLock{}
+	}
+
+	lock, ok := o.serviceLocks[key]
+	if !ok {
+		lock = &serviceLock{}
+		o.serviceLocks[key] = lock
+	}
+
+	lock.users++
+	o.serviceLocksMu.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		// Forget the lock once nobody waits for it so deleted services don't accumulate
+		o.serviceLocksMu.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(o.serviceLocks, key)
+		}
+		o.serviceLocksMu.Unlock()
+	}
+}
@@ -2,15 +2,276 @@ package cloudflare
 
 import (
 	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
 
+	cloudflareClient "github.com/ClyentSoftwares/cloudflare-cloud-controller-manager/pkg/cloudflare"
+	"github.com/cloudflare/cloudflare-go"
 	v1 "k8s.io/api/core/v1"
 )
 
-func GetNodeExternalIP(node *v1.Node) (string, error) {
-	for _, address := range node.Status.Addresses {
-		if address.Type == v1.NodeExternalIP {
-			return address.Address, nil
+// GetNodeOriginAddress returns the address of the given IP family of the node from the first source in sources
+// it has one for. Addresses that aren't IPs, like hostnames, match any family
+func GetNodeOriginAddress(node *v1.Node, sources []string, family v1.IPFamily) (string, error) {
+	for _, source := range sources {
+		var addresses []string
+
+		if source == originAddressSourceAnnotation {
+			// The annotation may list an address per family e.g 203.0.113.10,2001:db8::10
+			addresses = splitList(node.Annotations[nodeAnnotationOriginAddress], ",")
+		}
+
+		for _, address := range node.Status.Addresses {
+			if string(address.Type) == source && address.Address != "" {
+				addresses = append(addresses, address.Address)
+			}
+		}
+
+		for _, address := range addresses {
+			if ipFamilyMatches(address, family) {
+				return address, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no %s address of type %s found for node %v", family, strings.Join(sources, ", "), node.Name)
+}
+
+// ipFamilyMatches reports whether the address belongs to the IP family, addresses that aren't IPs match any family
+func ipFamilyMatches(address string, family v1.IPFamily) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return true
+	}
+
+	if ip.To4() != nil {
+		return family == v1.IPv4Protocol
+	}
+
+	return family == v1.IPv6Protocol
+}
+
+// loadBalancerMonitorEqual reports whether the fields of a monitor that are managed
+// through service annotations match
+func loadBalancerMonitorEqual(current cloudflare.LoadBalancerMonitor, desired cloudflare.LoadBalancerMonitor) bool {
+	return current.Type == desired.Type &&
+		current.Description == desired.Description &&
+		current.Method == desired.Method &&
+		current.Path == desired.Path &&
+		current.Port == desired.Port &&
+		current.ExpectedCodes == desired.ExpectedCodes &&
+		current.ExpectedBody == desired.ExpectedBody &&
+		current.Interval == desired.Interval &&
+		current.Timeout == desired.Timeout &&
+		current.Retries == desired.Retries &&
+		current.ConsecutiveUp == desired.ConsecutiveUp &&
+		current.ConsecutiveDown == desired.ConsecutiveDown &&
+		current.FollowRedirects == desired.FollowRedirects &&
+		current.AllowInsecure == desired.AllowInsecure &&
+		current.ProbeZone == desired.ProbeZone &&
+		headerEqual(current.Header, desired.Header)
+}
+
+// loadBalancerPoolEqual reports whether the fields of a pool that are managed through service
+// annotations and its origins match
+func loadBalancerPoolEqual(current cloudflare.LoadBalancerPool, desired cloudflare.LoadBalancerPool) bool {
+	return current.Description == desired.Description &&
+		current.Enabled == desired.Enabled &&
+		current.Monitor == desired.Monitor &&
+		current.NotificationEmail == desired.NotificationEmail &&
+		// cloudflare keeps the current value of settings that are left unset
+		(desired.MinimumOrigins == nil || (current.MinimumOrigins != nil && *current.MinimumOrigins == *desired.MinimumOrigins)) &&
+		(desired.LoadShedding == nil || (current.LoadShedding != nil && *current.LoadShedding == *desired.LoadShedding)) &&
+		originSteeringEqual(current.OriginSteering, desired.OriginSteering) &&
+		slices.Equal(current.CheckRegions, desired.CheckRegions) &&
+		originsEqual(current.Origins, desired.Origins)
+}
+
+// originSteeringEqual compares origin steering settings, cloudflare steers randomly when unset
+func originSteeringEqual(current *cloudflare.LoadBalancerOriginSteering, desired *cloudflare.LoadBalancerOriginSteering) bool {
+	policy := func(originSteering *cloudflare.LoadBalancerOriginSteering) string {
+		if originSteering == nil || originSteering.Policy == "" {
+			return "random"
+		}
+
+		return originSteering.Policy
+	}
+
+	return policy(current) == policy(desired)
+}
+
+// originsEqual compares the origins of two pools by name ignoring their order
+func originsEqual(current []cloudflare.LoadBalancerOrigin, desired []cloudflare.LoadBalancerOrigin) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+
+	currentByName := make(map[string]cloudflare.LoadBalancerOrigin, len(current))
+	for _, origin := range current {
+		currentByName[origin.Name] = origin
+	}
+
+	for _, origin := range desired {
+		currentOrigin, ok := currentByName[origin.Name]
+		if !ok ||
+			currentOrigin.Address != origin.Address ||
+			currentOrigin.Enabled != origin.Enabled ||
+			currentOrigin.Weight != origin.Weight ||
+			!headerEqual(currentOrigin.Header, origin.Header) {
+			return false
 		}
 	}
-	return "", fmt.Errorf("no external IP found for node %v. %v", node.Name, node)
+
+	return true
+}
+
+// loadBalancerEqual reports whether the fields of a load balancer that are managed
+// through service annotations match
+func loadBalancerEqual(current cloudflare.LoadBalancer, desired cloudflare.LoadBalancer) bool {
+	return current.Description == desired.Description &&
+		current.FallbackPool == desired.FallbackPool &&
+		slices.Equal(current.DefaultPools, desired.DefaultPools) &&
+		poolMappingEqual(current.RegionPools, desired.RegionPools) &&
+		poolMappingEqual(current.CountryPools, desired.CountryPools) &&
+		poolMappingEqual(current.PopPools, desired.PopPools) &&
+		current.Proxied == desired.Proxied &&
+		// cloudflare ignores the TTL of proxied load balancers
+		(desired.Proxied || current.TTL == desired.TTL) &&
+		current.SteeringPolicy == desired.SteeringPolicy &&
+		randomSteeringEqual(current.RandomSteering, desired.RandomSteering) &&
+		sessionAffinityEqual(current, desired) &&
+		adaptiveRoutingEqual(current.AdaptiveRouting, desired.AdaptiveRouting) &&
+		locationStrategyEqual(current.LocationStrategy, desired.LocationStrategy)
+}
+
+// adaptiveRoutingEqual compares adaptive routing settings, failover across pools is disabled when unset
+func adaptiveRoutingEqual(current *cloudflare.AdaptiveRouting, desired *cloudflare.AdaptiveRouting) bool {
+	failoverAcrossPools := func(adaptiveRouting *cloudflare.AdaptiveRouting) bool {
+		return adaptiveRouting != nil && adaptiveRouting.FailoverAcrossPools != nil && *adaptiveRouting.FailoverAcrossPools
+	}
+
+	return failoverAcrossPools(current) == failoverAcrossPools(desired)
+}
+
+// locationStrategyEqual compares location strategies. Cloudflare fills in defaults for
+// anything left unset, so only values that are explicitly desired are compared
+func locationStrategyEqual(current *cloudflare.LocationStrategy, desired *cloudflare.LocationStrategy) bool {
+	if desired == nil {
+		return true
+	}
+
+	if current == nil {
+		current = &cloudflare.LocationStrategy{}
+	}
+
+	return (desired.Mode == "" || current.Mode == desired.Mode) &&
+		(desired.PreferECS == "" || current.PreferECS == desired.PreferECS)
+}
+
+// sessionAffinityEqual compares the session affinity settings of two load balancers. Cloudflare fills
+// in defaults for anything left unset, so only values that are explicitly desired are compared
+func sessionAffinityEqual(current cloudflare.LoadBalancer, desired cloudflare.LoadBalancer) bool {
+	currentPersistence, desiredPersistence := current.Persistence, desired.Persistence
+	if currentPersistence == "" {
+		currentPersistence = "none"
+	}
+
+	if desiredPersistence == "" {
+		desiredPersistence = "none"
+	}
+
+	if currentPersistence != desiredPersistence {
+		return false
+	}
+
+	if desired.PersistenceTTL != 0 && current.PersistenceTTL != desired.PersistenceTTL {
+		return false
+	}
+
+	if desired.SessionAffinityAttributes == nil {
+		return true
+	}
+
+	currentAttributes, desiredAttributes := current.SessionAffinityAttributes, desired.SessionAffinityAttributes
+	if currentAttributes == nil {
+		currentAttributes = &cloudflare.SessionAffinityAttributes{}
+	}
+
+	return (desiredAttributes.SameSite == "" || currentAttributes.SameSite == desiredAttributes.SameSite) &&
+		(desiredAttributes.Secure == "" || currentAttributes.Secure == desiredAttributes.Secure) &&
+		(desiredAttributes.ZeroDowntimeFailover == "" || currentAttributes.ZeroDowntimeFailover == desiredAttributes.ZeroDowntimeFailover) &&
+		currentAttributes.DrainDuration == desiredAttributes.DrainDuration &&
+		currentAttributes.RequireAllHeaders == desiredAttributes.RequireAllHeaders &&
+		slices.Equal(currentAttributes.Headers, desiredAttributes.Headers)
+}
+
+// poolMappingEqual compares region, country or pop pool mappings treating an unset mapping as empty
+func poolMappingEqual(a map[string][]string, b map[string][]string) bool {
+	return maps.EqualFunc(a, b, slices.Equal[[]string])
+}
+
+// randomSteeringEqual compares random steering settings treating an unset value as empty
+func randomSteeringEqual(a *cloudflare.RandomSteering, b *cloudflare.RandomSteering) bool {
+	if a == nil {
+		a = &cloudflare.RandomSteering{}
+	}
+
+	if b == nil {
+		b = &cloudflare.RandomSteering{}
+	}
+
+	return a.DefaultWeight == b.DefaultWeight && maps.Equal(a.PoolWeights, b.PoolWeights)
+}
+
+// headerEqual compares two header maps ignoring keys without any values, as
+// cloudflare does not return those
+func headerEqual(a map[string][]string, b map[string][]string) bool {
+	for key, values := range a {
+		if len(values) > 0 && !slices.Equal(values, b[key]) {
+			return false
+		}
+	}
+
+	for key, values := range b {
+		if len(values) > 0 && !slices.Equal(values, a[key]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// notificationFilterEqual compares two notification filters, a missing filter or target
+// notifies on every health change just like an empty one
+func notificationFilterEqual(a *cloudflareClient.LoadBalancerPoolNotificationFilter, b *cloudflareClient.LoadBalancerPoolNotificationFilter) bool {
+	targets := func(filter *cloudflareClient.LoadBalancerPoolNotificationFilter) [2]cloudflareClient.LoadBalancerPoolNotificationFilterTarget {
+		var result [2]cloudflareClient.LoadBalancerPoolNotificationFilterTarget
+		if filter == nil {
+			return result
+		}
+
+		for i, target := range []*cloudflareClient.LoadBalancerPoolNotificationFilterTarget{filter.Origin, filter.Pool} {
+			if target != nil {
+				result[i] = *target
+			}
+		}
+
+		return result
+	}
+
+	ta, tb := targets(a), targets(b)
+
+	for i := range ta {
+		if ta[i].Disable != tb[i].Disable || (ta[i].Healthy == nil) != (tb[i].Healthy == nil) {
+			return false
+		}
+
+		if ta[i].Healthy != nil && *ta[i].Healthy != *tb[i].Healthy {
+			return false
+		}
+	}
+
+	return true
 }
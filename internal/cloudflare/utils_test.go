@@ -0,0 +1,210 @@
+package cloudflare
+
+import (
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestLoadBalancerEqual(t *testing.T) {
+	enabled := true
+
+	current := cloudflare.LoadBalancer{
+		Description:  "marker",
+		FallbackPool: "pool-1",
+		DefaultPools: []string{"pool-1"},
+		Proxied:      true,
+		TTL:          30,
+		Persistence:  "none",
+		LocationStrategy: &cloudflare.LocationStrategy{
+			Mode:      "pop",
+			PreferECS: "proximity",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(desired *cloudflare.LoadBalancer)
+		want   bool
+	}{
+		{
+			name:   "equal",
+			modify: func(desired *cloudflare.LoadBalancer) {},
+			want:   true,
+		},
+		{
+			name:   "description",
+			modify: func(desired *cloudflare.LoadBalancer) { desired.Description = "other" },
+			want:   false,
+		},
+		{
+			name:   "default pools",
+			modify: func(desired *cloudflare.LoadBalancer) { desired.DefaultPools = []string{"pool-2", "pool-1"} },
+			want:   false,
+		},
+		{
+			name:   "ttl of proxied load balancers is ignored",
+			modify: func(desired *cloudflare.LoadBalancer) { desired.TTL = 60 },
+			want:   true,
+		},
+		{
+			name: "ttl of dns only load balancers",
+			modify: func(desired *cloudflare.LoadBalancer) {
+				desired.Proxied = false
+				desired.TTL = 60
+			},
+			want: false,
+		},
+		{
+			name:   "unset persistence is none",
+			modify: func(desired *cloudflare.LoadBalancer) { desired.Persistence = "" },
+			want:   true,
+		},
+		{
+			name:   "unset location strategy keeps the current one",
+			modify: func(desired *cloudflare.LoadBalancer) { desired.LocationStrategy = nil },
+			want:   true,
+		},
+		{
+			name: "location strategy",
+			modify: func(desired *cloudflare.LoadBalancer) {
+				desired.LocationStrategy = &cloudflare.LocationStrategy{Mode: "resolver_ip"}
+			},
+			want: false,
+		},
+		{
+			name: "adaptive routing",
+			modify: func(desired *cloudflare.LoadBalancer) {
+				desired.AdaptiveRouting = &cloudflare.AdaptiveRouting{FailoverAcrossPools: &enabled}
+			},
+			want: false,
+		},
+		{
+			name:   "empty random steering is unset",
+			modify: func(desired *cloudflare.LoadBalancer) { desired.RandomSteering = &cloudflare.RandomSteering{} },
+			want:   true,
+		},
+		{
+			name:   "empty region pools are unset",
+			modify: func(desired *cloudflare.LoadBalancer) { desired.RegionPools = map[string][]string{} },
+			want:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			desired := current
+			test.modify(&desired)
+
+			if got := loadBalancerEqual(current, desired); got != test.want {
+				t.Errorf("loadBalancerEqual() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerPoolEqual(t *testing.T) {
+	minimumOrigins := 2
+
+	current := cloudflare.LoadBalancerPool{
+		Description:    "marker",
+		Enabled:        true,
+		Monitor:        "monitor-1",
+		MinimumOrigins: &minimumOrigins,
+		CheckRegions:   []string{"WEU"},
+		Origins: []cloudflare.LoadBalancerOrigin{
+			{Name: "node-a-ipv4", Address: "203.0.113.10", Enabled: true, Weight: 1},
+			{Name: "node-b-ipv4", Address: "203.0.113.11", Enabled: true, Weight: 0.5},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(desired *cloudflare.LoadBalancerPool)
+		want   bool
+	}{
+		{
+			name:   "equal",
+			modify: func(desired *cloudflare.LoadBalancerPool) {},
+			want:   true,
+		},
+		{
+			name:   "monitor",
+			modify: func(desired *cloudflare.LoadBalancerPool) { desired.Monitor = "monitor-2" },
+			want:   false,
+		},
+		{
+			name:   "unset minimum origins keeps the current one",
+			modify: func(desired *cloudflare.LoadBalancerPool) { desired.MinimumOrigins = nil },
+			want:   true,
+		},
+		{
+			name: "minimum origins",
+			modify: func(desired *cloudflare.LoadBalancerPool) {
+				other := 1
+				desired.MinimumOrigins = &other
+			},
+			want: false,
+		},
+		{
+			name: "random origin steering is unset",
+			modify: func(desired *cloudflare.LoadBalancerPool) {
+				desired.OriginSteering = &cloudflare.LoadBalancerOriginSteering{Policy: "random"}
+			},
+			want: true,
+		},
+		{
+			name: "origin steering",
+			modify: func(desired *cloudflare.LoadBalancerPool) {
+				desired.OriginSteering = &cloudflare.LoadBalancerOriginSteering{Policy: "hash"}
+			},
+			want: false,
+		},
+		{
+			name:   "check regions",
+			modify: func(desired *cloudflare.LoadBalancerPool) { desired.CheckRegions = []string{"WNAM"} },
+			want:   false,
+		},
+		{
+			name: "origins in another order",
+			modify: func(desired *cloudflare.LoadBalancerPool) {
+				desired.Origins = []cloudflare.LoadBalancerOrigin{current.Origins[1], current.Origins[0]}
+			},
+			want: true,
+		},
+		{
+			name: "origin weight",
+			modify: func(desired *cloudflare.LoadBalancerPool) {
+				desired.Origins = []cloudflare.LoadBalancerOrigin{current.Origins[0], current.Origins[1]}
+				desired.Origins[1].Weight = 1
+			},
+			want: false,
+		},
+		{
+			name: "draining origin",
+			modify: func(desired *cloudflare.LoadBalancerPool) {
+				desired.Origins = []cloudflare.LoadBalancerOrigin{current.Origins[0], current.Origins[1]}
+				desired.Origins[0].Enabled = false
+			},
+			want: false,
+		},
+		{
+			name: "origin removed",
+			modify: func(desired *cloudflare.LoadBalancerPool) {
+				desired.Origins = current.Origins[:1]
+			},
+			want: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			desired := current
+			test.modify(&desired)
+
+			if got := loadBalancerPoolEqual(current, desired); got != test.want {
+				t.Errorf("loadBalancerPoolEqual() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
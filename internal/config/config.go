@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -13,6 +14,17 @@ const (
 	cloudflareZoneId    = "CLOUDFLARE_ZONE_ID"
 	cloudflareAccountId = "CLOUDFLARE_ACCOUNT_ID"
 
+	poolNotificationEmail  = "CLOUDFLARE_POOL_NOTIFICATION_EMAIL"
+	poolNotificationFilter = "CLOUDFLARE_POOL_NOTIFICATION_FILTER"
+	originAddressSources   = "CLOUDFLARE_ORIGIN_ADDRESS_SOURCES"
+	originNodeSelector     = "CLOUDFLARE_ORIGIN_NODE_SELECTOR"
+	clusterID              = "CLOUDFLARE_CLUSTER_ID"
+	adoptUnmarkedResources = "CLOUDFLARE_ADOPT_UNMARKED_RESOURCES"
+
+	garbageCollectorInterval    = "CLOUDFLARE_GC_INTERVAL"
+	garbageCollectorGracePeriod = "CLOUDFLARE_GC_GRACE_PERIOD"
+	garbageCollectorReportOnly  = "CLOUDFLARE_GC_REPORT_ONLY"
+
 	debug = "DEBUG"
 )
 
@@ -23,8 +35,34 @@ type CloudflareClientConfiguration struct {
 	Debug     bool
 }
 
+// LoadBalancerConfiguration holds the cluster wide defaults of load balancers, services can override them with annotations
+type LoadBalancerConfiguration struct {
+	PoolNotificationEmail  string
+	PoolNotificationFilter string
+	OriginAddressSources   string
+	OriginNodeSelector     string
+	// ClusterID identifies the cluster in the ownership marker of cloudflare resources. Defaults to the
+	// --cluster-name passed by the controller manager when unset
+	ClusterID string
+	// AdoptUnmarkedResources takes over resources without ownership marker, which were created by older versions, when
+	// updating them. Enabled by default so existing services keep working after an upgrade. Unmarked resources are never deleted
+	AdoptUnmarkedResources bool
+}
+
+// GarbageCollectorConfiguration configures the removal of cloudflare resources whose service no longer exists
+type GarbageCollectorConfiguration struct {
+	// Interval between runs, zero disables the garbage collector
+	Interval time.Duration
+	// GracePeriod a resource has to be orphaned for before it is deleted
+	GracePeriod time.Duration
+	// ReportOnly logs orphans without deleting them, enabled by default
+	ReportOnly bool
+}
+
 type CloudflareCCMConfiguration struct {
 	CloudflareClient CloudflareClientConfiguration
+	LoadBalancer     LoadBalancerConfiguration
+	GarbageCollector GarbageCollectorConfiguration
 }
 
 // read values from environment variables or from file set via _FILE env var
@@ -78,6 +116,51 @@ func Read() (CloudflareCCMConfiguration, error) {
 		errs = append(errs, err)
 	}
 
+	cfg.LoadBalancer.PoolNotificationEmail, err = readFromEnvOrFile(poolNotificationEmail)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg.LoadBalancer.PoolNotificationFilter, err = readFromEnvOrFile(poolNotificationFilter)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg.LoadBalancer.OriginAddressSources, err = readFromEnvOrFile(originAddressSources)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg.LoadBalancer.OriginNodeSelector, err = readFromEnvOrFile(originNodeSelector)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg.LoadBalancer.ClusterID, err = readFromEnvOrFile(clusterID)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg.LoadBalancer.AdoptUnmarkedResources, err = getEnvBool(adoptUnmarkedResources, true)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg.GarbageCollector.Interval, err = getEnvDuration(garbageCollectorInterval, 10*time.Minute)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg.GarbageCollector.GracePeriod, err = getEnvDuration(garbageCollectorGracePeriod, time.Hour)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg.GarbageCollector.ReportOnly, err = getEnvBool(garbageCollectorReportOnly, true)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
 	cfg.CloudflareClient.Debug, err = getEnvBool(debug, false)
 	if err != nil {
 		errs = append(errs, err)
@@ -100,6 +183,14 @@ func (c CloudflareCCMConfiguration) Validate() (err error) {
 		errs = append(errs, fmt.Errorf("environment variable %q is required", cloudflareAPIToken))
 	}
 
+	if c.GarbageCollector.Interval < 0 {
+		errs = append(errs, fmt.Errorf("environment variable %q must not be negative", garbageCollectorInterval))
+	}
+
+	if c.GarbageCollector.GracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("environment variable %q must not be negative", garbageCollectorGracePeriod))
+	}
+
 	if len(errs) > 0 {
 		return errors.Join(errs...)
 	}
@@ -121,3 +212,19 @@ func getEnvBool(key string, defaultValue bool) (bool, error) {
 
 	return b, nil
 }
+
+// getEnvDuration returns the duration parsed from the environment variable with the given key and a potential error
+// parsing the var. Returns the default value if the env var is unset.
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %v", key, err)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,95 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// ErrNotFound is returned when a resource does not exist on cloudflare.
+var ErrNotFound = errors.New("resource not found")
+
+// APIError wraps an error returned by the cloudflare API with the category the cloudflare client classified it as.
+// Type is empty when the cloudflare client returned an untyped error e.g. on network errors or once its retries of
+// rate limited (429) and failed (5xx) requests are exhausted.
+type APIError struct {
+	Type cloudflare.ErrorType
+	// StatusCode is the HTTP status code of the response when the category determines it, zero otherwise.
+	// Request errors cover every 4xx status without a category of its own and service errors every 5xx status.
+	StatusCode int
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.Type == "":
+		return e.Err.Error()
+	case e.StatusCode == 0:
+		return fmt.Sprintf("cloudflare API %s error: %v", e.Type, e.Err)
+	}
+
+	return fmt.Sprintf("cloudflare API %s error (HTTP %d): %v", e.Type, e.StatusCode, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is allows errors.Is(err, ErrNotFound) to match API errors for missing resources.
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.Type == cloudflare.ErrorTypeNotFound
+}
+
+// newAPIError wraps err in an [APIError] carrying the category of the cloudflare response.
+func newAPIError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	errorType, statusCode := classifyError(err)
+
+	return &APIError{
+		Type:       errorType,
+		StatusCode: statusCode,
+		Err:        err,
+	}
+}
+
+// classifyError derives the category and, where it determines one, the HTTP status code from the typed errors of
+// the cloudflare client. Note the client returns authorization errors for 401 and authentication errors for 403.
+// Rate limit and service errors are only classified when the client returns them typed, it retries those statuses
+// and reports an untyped error once the retries are exhausted.
+func classifyError(err error) (cloudflare.ErrorType, int) {
+	var (
+		notFoundErr       *cloudflare.NotFoundError
+		ratelimitErr      *cloudflare.RatelimitError
+		authorizationErr  *cloudflare.AuthorizationError
+		authenticationErr *cloudflare.AuthenticationError
+		requestErr        *cloudflare.RequestError
+		serviceErr        *cloudflare.ServiceError
+	)
+
+	switch {
+	case errors.As(err, &notFoundErr):
+		return cloudflare.ErrorTypeNotFound, http.StatusNotFound
+	case errors.As(err, &ratelimitErr):
+		return cloudflare.ErrorTypeRateLimit, http.StatusTooManyRequests
+	case errors.As(err, &authorizationErr):
+		return cloudflare.ErrorTypeAuthorization, http.StatusUnauthorized
+	case errors.As(err, &authenticationErr):
+		return cloudflare.ErrorTypeAuthentication, http.StatusForbidden
+	case errors.As(err, &requestErr):
+		return cloudflare.ErrorTypeRequest, 0
+	case errors.As(err, &serviceErr):
+		return cloudflare.ErrorTypeService, 0
+	}
+
+	return "", 0
+}
+
+// IsNotFound reports whether err signals that a resource does not exist on cloudflare.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
@@ -0,0 +1,128 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		wantType       cloudflare.ErrorType
+		wantStatusCode int
+		wantNotFound   bool
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized, wantType: cloudflare.ErrorTypeAuthorization, wantStatusCode: http.StatusUnauthorized},
+		{name: "forbidden", status: http.StatusForbidden, wantType: cloudflare.ErrorTypeAuthentication, wantStatusCode: http.StatusForbidden},
+		{name: "not found", status: http.StatusNotFound, wantType: cloudflare.ErrorTypeNotFound, wantStatusCode: http.StatusNotFound, wantNotFound: true},
+		{name: "bad request", status: http.StatusBadRequest, wantType: cloudflare.ErrorTypeRequest},
+		{name: "conflict", status: http.StatusConflict, wantType: cloudflare.ErrorTypeRequest},
+		// The client retries 429 and 5xx responses and returns an untyped error once the retries are exhausted.
+		{name: "too many requests", status: http.StatusTooManyRequests},
+		{name: "internal server error", status: http.StatusInternalServerError},
+		{name: "service unavailable", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":1000,"message":"test error"}],"messages":[],"result":null}`))
+			}))
+			defer server.Close()
+
+			err := newTestCloudflareAPI(t, server.URL).DeleteLoadBalancerByID(context.Background(), "lb")
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected an APIError, got %v", err)
+			}
+			if apiErr.Type != tt.wantType {
+				t.Errorf("Type = %q, want %q", apiErr.Type, tt.wantType)
+			}
+			if apiErr.StatusCode != tt.wantStatusCode {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tt.wantStatusCode)
+			}
+			if IsNotFound(err) != tt.wantNotFound {
+				t.Errorf("IsNotFound = %v, want %v", IsNotFound(err), tt.wantNotFound)
+			}
+		})
+	}
+}
+
+func TestNewAPIErrorWithoutResponse(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := newTestCloudflareAPI(t, server.URL).DeleteLoadBalancerByID(context.Background(), "lb")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.Type != "" || apiErr.StatusCode != 0 {
+		t.Errorf("expected an unclassified error, got type %q and status code %d", apiErr.Type, apiErr.StatusCode)
+	}
+	if IsNotFound(err) {
+		t.Error("expected a network error not to be reported as not found")
+	}
+}
+
+func TestNewAPIErrorTyped(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantType       cloudflare.ErrorType
+		wantStatusCode int
+	}{
+		{name: "rate limit", err: ptr(cloudflare.NewRatelimitError(&cloudflare.Error{StatusCode: http.StatusTooManyRequests})), wantType: cloudflare.ErrorTypeRateLimit, wantStatusCode: http.StatusTooManyRequests},
+		{name: "service", err: ptr(cloudflare.NewServiceError(&cloudflare.Error{StatusCode: http.StatusBadGateway})), wantType: cloudflare.ErrorTypeService},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr *APIError
+			if !errors.As(newAPIError(tt.err), &apiErr) {
+				t.Fatalf("expected an APIError")
+			}
+			if apiErr.Type != tt.wantType {
+				t.Errorf("Type = %q, want %q", apiErr.Type, tt.wantType)
+			}
+			if apiErr.StatusCode != tt.wantStatusCode {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestNewAPIErrorNil(t *testing.T) {
+	if err := newAPIError(nil); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func newTestCloudflareAPI(t *testing.T, baseURL string) *CloudflareAPI {
+	t.Helper()
+
+	client, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(baseURL), cloudflare.UsingRetryPolicy(0, 0, 0))
+	if err != nil {
+		t.Fatalf("creating cloudflare client: %v", err)
+	}
+
+	return &CloudflareAPI{
+		CloudflareClient: client,
+		AccountId:        "account",
+		ZoneId:           "zone",
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -3,6 +3,7 @@ package cloudflare
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/cloudflare/cloudflare-go"
 )
@@ -13,7 +14,7 @@ func (c *CloudflareAPI) GetLoadBalancer(ctx context.Context, name string) (cloud
 	lbs, err := c.CloudflareClient.ListLoadBalancers(ctx, cloudflare.ZoneIdentifier(c.ZoneId), cloudflare.ListLoadBalancerParams{})
 	if err != nil {
 		c.Log.Error(err, "error listing load balancers", "zoneID", c.ZoneId)
-		return cloudflare.LoadBalancer{}, fmt.Errorf("error listing load balancers: %w", err)
+		return cloudflare.LoadBalancer{}, fmt.Errorf("error listing load balancers: %w", newAPIError(err))
 	}
 
 	for _, lb := range lbs {
@@ -22,7 +23,7 @@ func (c *CloudflareAPI) GetLoadBalancer(ctx context.Context, name string) (cloud
 		}
 	}
 
-	return cloudflare.LoadBalancer{}, fmt.Errorf("failed to get load balancer by name: %v", name)
+	return cloudflare.LoadBalancer{}, fmt.Errorf("failed to get load balancer by name %v: %w", name, ErrNotFound)
 }
 
 // creates a new load balancer for a given zone ID.
@@ -36,13 +37,31 @@ func (c *CloudflareAPI) CreateLoadBalancer(ctx context.Context, loadBalancer clo
 
 	if err != nil {
 		c.Log.Error(err, "error creating load balancer", "zoneID", c.ZoneId, "name", loadBalancer.Name)
-		return cloudflare.LoadBalancer{}, fmt.Errorf("error creating load balancer: %w", err)
+		return cloudflare.LoadBalancer{}, fmt.Errorf("error creating load balancer: %w", newAPIError(err))
 	}
 
 	c.Log.Info("load balancer created successfully", "zoneID", c.ZoneId, "name", loadBalancer.Name, "response", response)
 	return response, nil
 }
 
+// updates an existing load balancer for a given zone ID.
+func (c *CloudflareAPI) UpdateLoadBalancer(ctx context.Context, loadBalancer cloudflare.LoadBalancer) (cloudflare.LoadBalancer, error) {
+
+	params := cloudflare.UpdateLoadBalancerParams{
+		LoadBalancer: loadBalancer,
+	}
+
+	response, err := c.CloudflareClient.UpdateLoadBalancer(ctx, cloudflare.ZoneIdentifier(c.ZoneId), params)
+
+	if err != nil {
+		c.Log.Error(err, "error updating load balancer", "zoneID", c.ZoneId, "name", loadBalancer.Name)
+		return cloudflare.LoadBalancer{}, fmt.Errorf("error updating load balancer: %w", newAPIError(err))
+	}
+
+	c.Log.Info("load balancer updated successfully", "zoneID", c.ZoneId, "name", loadBalancer.Name, "response", response)
+	return response, nil
+}
+
 // delete a load balancer by name.
 func (c *CloudflareAPI) DeleteLoadBalancer(ctx context.Context, name string) error {
 
@@ -53,7 +72,7 @@ func (c *CloudflareAPI) DeleteLoadBalancer(ctx context.Context, name string) err
 
 	err = c.CloudflareClient.DeleteLoadBalancer(ctx, cloudflare.ZoneIdentifier(c.ZoneId), lb.ID)
 
-	return err
+	return newAPIError(err)
 }
 
 // gets a pool by name.
@@ -62,7 +81,7 @@ func (c *CloudflareAPI) GetLoadBalancerPool(ctx context.Context, poolName string
 	pools, err := c.CloudflareClient.ListLoadBalancerPools(ctx, cloudflare.AccountIdentifier(c.AccountId), cloudflare.ListLoadBalancerPoolParams{})
 	if err != nil {
 		c.Log.Error(err, "error listing load balancer pools")
-		return cloudflare.LoadBalancerPool{}, err
+		return cloudflare.LoadBalancerPool{}, fmt.Errorf("error listing load balancer pools: %w", newAPIError(err))
 	}
 
 	for _, pool := range pools {
@@ -71,7 +90,7 @@ func (c *CloudflareAPI) GetLoadBalancerPool(ctx context.Context, poolName string
 		}
 	}
 
-	return cloudflare.LoadBalancerPool{}, fmt.Errorf("failed to get load balancer pool by name: %v", poolName)
+	return cloudflare.LoadBalancerPool{}, fmt.Errorf("failed to get load balancer pool by name %v: %w", poolName, ErrNotFound)
 }
 
 // creates a new pool.
@@ -83,7 +102,7 @@ func (c *CloudflareAPI) CreateLoadBalancerPool(ctx context.Context, loadBalancer
 	pool, err := c.CloudflareClient.CreateLoadBalancerPool(ctx, cloudflare.AccountIdentifier(c.AccountId), params)
 	if err != nil {
 		c.Log.Error(err, "error creating load balancer pool")
-		return cloudflare.LoadBalancerPool{}, err
+		return cloudflare.LoadBalancerPool{}, newAPIError(err)
 	}
 
 	return pool, nil
@@ -98,7 +117,7 @@ func (c *CloudflareAPI) UpdateLoadBalancerPool(ctx context.Context, loadBalancer
 	pool, err := c.CloudflareClient.UpdateLoadBalancerPool(ctx, cloudflare.AccountIdentifier(c.AccountId), params)
 	if err != nil {
 		c.Log.Error(err, "error updating load balancer pool")
-		return cloudflare.LoadBalancerPool{}, err
+		return cloudflare.LoadBalancerPool{}, newAPIError(err)
 	}
 
 	return pool, nil
@@ -111,9 +130,14 @@ func (c *CloudflareAPI) DeleteLoadBalancerPool(ctx context.Context, poolName str
 		return err
 	}
 
-	err = c.CloudflareClient.DeleteLoadBalancerPool(ctx, cloudflare.AccountIdentifier(c.AccountId), pool.ID)
+	return c.DeleteLoadBalancerPoolByID(ctx, pool.ID)
+}
+
+// delete a pool by ID.
+func (c *CloudflareAPI) DeleteLoadBalancerPoolByID(ctx context.Context, poolId string) error {
+	err := c.CloudflareClient.DeleteLoadBalancerPool(ctx, cloudflare.AccountIdentifier(c.AccountId), poolId)
 
-	return err
+	return newAPIError(err)
 }
 
 // gets the configuration of an existing pool.
@@ -122,7 +146,7 @@ func (c *CloudflareAPI) GetPoolConfiguration(ctx context.Context, poolId string)
 	pool, err := c.CloudflareClient.GetLoadBalancerPool(ctx, cloudflare.AccountIdentifier(c.AccountId), poolId)
 	if err != nil {
 		c.Log.Error(err, "error fetching load balancer pool", "poolId", poolId)
-		return cloudflare.LoadBalancerPool{}, err
+		return cloudflare.LoadBalancerPool{}, newAPIError(err)
 	}
 
 	return pool, nil
@@ -138,28 +162,28 @@ func (c *CloudflareAPI) UpdatePoolConfiguration(ctx context.Context, loadBalance
 	_, err := c.CloudflareClient.UpdateLoadBalancerPool(ctx, cloudflare.AccountIdentifier(c.AccountId), params)
 	if err != nil {
 		c.Log.Error(err, "error updating load balancer pool", "poolId", loadBalancerPool.ID)
-		return err
+		return newAPIError(err)
 	}
 
 	return nil
 }
 
-// retrieves a health monitor by name.
+// retrieves a health monitor by name. Monitors have no name so it is stored as the first word of the description.
 func (c *CloudflareAPI) GetLoadBalancerMonitor(ctx context.Context, monitorName string) (cloudflare.LoadBalancerMonitor, error) {
 
 	monitors, err := c.CloudflareClient.ListLoadBalancerMonitors(ctx, cloudflare.AccountIdentifier(c.AccountId), cloudflare.ListLoadBalancerMonitorParams{})
 	if err != nil {
 		c.Log.Error(err, "error listing load balancer monitors")
-		return cloudflare.LoadBalancerMonitor{}, err
+		return cloudflare.LoadBalancerMonitor{}, fmt.Errorf("error listing load balancer monitors: %w", newAPIError(err))
 	}
 
 	for _, monitor := range monitors {
-		if monitor.Description == monitorName {
+		if name, _, _ := strings.Cut(monitor.Description, " "); name == monitorName {
 			return monitor, nil
 		}
 	}
 
-	return cloudflare.LoadBalancerMonitor{}, fmt.Errorf("failed to get load balancer monitor by name: %v", monitorName)
+	return cloudflare.LoadBalancerMonitor{}, fmt.Errorf("failed to get load balancer monitor by name %v: %w", monitorName, ErrNotFound)
 }
 
 // creates a new health monitor.
@@ -171,7 +195,7 @@ func (c *CloudflareAPI) CreateLoadBalancerMonitor(ctx context.Context, monitor c
 	monitor, err := c.CloudflareClient.CreateLoadBalancerMonitor(ctx, cloudflare.AccountIdentifier(c.AccountId), params)
 	if err != nil {
 		c.Log.Error(err, "error creating load balancer monitor")
-		return cloudflare.LoadBalancerMonitor{}, err
+		return cloudflare.LoadBalancerMonitor{}, newAPIError(err)
 	}
 
 	return monitor, nil
@@ -185,8 +209,8 @@ func (c *CloudflareAPI) UpdateLoadBalancerMonitor(ctx context.Context, monitor c
 
 	monitor, err := c.CloudflareClient.UpdateLoadBalancerMonitor(ctx, cloudflare.AccountIdentifier(c.AccountId), params)
 	if err != nil {
-		c.Log.Error(err, "error creating load balancer monitor", "monitorId", monitor.ID)
-		return cloudflare.LoadBalancerMonitor{}, err
+		c.Log.Error(err, "error updating load balancer monitor", "monitorId", params.LoadBalancerMonitor.ID)
+		return cloudflare.LoadBalancerMonitor{}, newAPIError(err)
 	}
 
 	return monitor, nil
@@ -202,5 +226,55 @@ func (c *CloudflareAPI) DeleteLoadBalancerMonitor(ctx context.Context, name stri
 
 	err = c.CloudflareClient.DeleteLoadBalancerMonitor(ctx, cloudflare.AccountIdentifier(c.AccountId), monitor.ID)
 
-	return err
+	return newAPIError(err)
+}
+
+// lists every load balancer of the zone.
+func (c *CloudflareAPI) ListLoadBalancers(ctx context.Context) ([]cloudflare.LoadBalancer, error) {
+
+	lbs, err := c.CloudflareClient.ListLoadBalancers(ctx, cloudflare.ZoneIdentifier(c.ZoneId), cloudflare.ListLoadBalancerParams{})
+	if err != nil {
+		c.Log.Error(err, "error listing load balancers", "zoneID", c.ZoneId)
+		return nil, fmt.Errorf("error listing load balancers: %w", newAPIError(err))
+	}
+
+	return lbs, nil
+}
+
+// delete a load balancer by ID.
+func (c *CloudflareAPI) DeleteLoadBalancerByID(ctx context.Context, loadBalancerId string) error {
+	err := c.CloudflareClient.DeleteLoadBalancer(ctx, cloudflare.ZoneIdentifier(c.ZoneId), loadBalancerId)
+
+	return newAPIError(err)
+}
+
+// lists every pool of the account.
+func (c *CloudflareAPI) ListLoadBalancerPools(ctx context.Context) ([]cloudflare.LoadBalancerPool, error) {
+
+	pools, err := c.CloudflareClient.ListLoadBalancerPools(ctx, cloudflare.AccountIdentifier(c.AccountId), cloudflare.ListLoadBalancerPoolParams{})
+	if err != nil {
+		c.Log.Error(err, "error listing load balancer pools")
+		return nil, fmt.Errorf("error listing load balancer pools: %w", newAPIError(err))
+	}
+
+	return pools, nil
+}
+
+// lists every health monitor of the account.
+func (c *CloudflareAPI) ListLoadBalancerMonitors(ctx context.Context) ([]cloudflare.LoadBalancerMonitor, error) {
+
+	monitors, err := c.CloudflareClient.ListLoadBalancerMonitors(ctx, cloudflare.AccountIdentifier(c.AccountId), cloudflare.ListLoadBalancerMonitorParams{})
+	if err != nil {
+		c.Log.Error(err, "error listing load balancer monitors")
+		return nil, fmt.Errorf("error listing load balancer monitors: %w", newAPIError(err))
+	}
+
+	return monitors, nil
+}
+
+// delete a load balancer monitor by ID.
+func (c *CloudflareAPI) DeleteLoadBalancerMonitorByID(ctx context.Context, monitorId string) error {
+	err := c.CloudflareClient.DeleteLoadBalancerMonitor(ctx, cloudflare.AccountIdentifier(c.AccountId), monitorId)
+
+	return newAPIError(err)
 }
@@ -0,0 +1,64 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// LoadBalancerPoolNotificationFilter filters the health notifications sent for a pool.
+// cloudflare-go does not support it yet so it is read and written with raw requests.
+type LoadBalancerPoolNotificationFilter struct {
+	Origin *LoadBalancerPoolNotificationFilterTarget `json:"origin,omitempty"`
+	Pool   *LoadBalancerPoolNotificationFilterTarget `json:"pool,omitempty"`
+}
+
+// LoadBalancerPoolNotificationFilterTarget filters the notifications of either the origins or the pool itself.
+// Healthy set to true only notifies when becoming healthy, false only when becoming unhealthy and nil on both.
+type LoadBalancerPoolNotificationFilterTarget struct {
+	Disable bool  `json:"disable"`
+	Healthy *bool `json:"healthy"`
+}
+
+func (c *CloudflareAPI) loadBalancerPoolEndpoint(poolId string) string {
+	return fmt.Sprintf("/accounts/%s/load_balancers/pools/%s", c.AccountId, poolId)
+}
+
+// gets the notification filter of a pool by ID, nil if none is set.
+func (c *CloudflareAPI) GetLoadBalancerPoolNotificationFilter(ctx context.Context, poolId string) (*LoadBalancerPoolNotificationFilter, error) {
+
+	response, err := c.CloudflareClient.Raw(ctx, http.MethodGet, c.loadBalancerPoolEndpoint(poolId), nil, nil)
+	if err != nil {
+		c.Log.Error(err, "error fetching load balancer pool", "poolId", poolId)
+		return nil, newAPIError(err)
+	}
+
+	var pool struct {
+		NotificationFilter *LoadBalancerPoolNotificationFilter `json:"notification_filter"`
+	}
+
+	if err := json.Unmarshal(response.Result, &pool); err != nil {
+		return nil, fmt.Errorf("error decoding load balancer pool %s: %w", poolId, err)
+	}
+
+	return pool.NotificationFilter, nil
+}
+
+// updates the notification filter of a pool by ID, a nil filter removes it.
+func (c *CloudflareAPI) UpdateLoadBalancerPoolNotificationFilter(ctx context.Context, poolId string, filter *LoadBalancerPoolNotificationFilter) error {
+
+	params := struct {
+		NotificationFilter *LoadBalancerPoolNotificationFilter `json:"notification_filter"`
+	}{
+		NotificationFilter: filter,
+	}
+
+	_, err := c.CloudflareClient.Raw(ctx, http.MethodPatch, c.loadBalancerPoolEndpoint(poolId), params, nil)
+	if err != nil {
+		c.Log.Error(err, "error updating load balancer pool notification filter", "poolId", poolId)
+		return newAPIError(err)
+	}
+
+	return nil
+}